@@ -1,7 +1,9 @@
 package x
 
 import (
+	"context"
 	"sync/atomic"
+	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2"
 	"gitlab.com/tozd/go/errors"
@@ -40,3 +42,131 @@ func (c *LRUCache[K, V]) Get(key K) (V, bool) { //nolint:ireturn
 func (c *LRUCache[K, V]) MissCount() uint64 {
 	return atomic.SwapUint64(&c.missCount, 0)
 }
+
+// cachedError is an error returned by the loader together with the time
+// until which it is cached.
+type cachedError struct {
+	err     errors.E
+	expires time.Time
+}
+
+// LoadingLRUCache is a LRU cache which loads missing values using a loader
+// function. Errors returned by the loader are cached as well (negative caching),
+// in a separate LRU cache with its own size and for a limited time.
+//
+// Misses (calls to the loader) are counted by MissCount, while requests
+// answered from cached errors are counted by ErrorHitCount.
+type LoadingLRUCache[K comparable, V any] struct {
+	*LRUCache[K, V]
+
+	errorCache *lru.Cache[K, cachedError]
+	errorTTL   time.Duration
+	loader     func(context.Context, K) (V, errors.E)
+	clock      Clock
+
+	errorHitCount uint64
+}
+
+// NewLoadingLRUCache creates a new loading LRU cache with the specified size
+// for values and the specified size for errors. Errors returned by loader are
+// cached for errorTTL.
+func NewLoadingLRUCache[K comparable, V any](
+	size, errorSize int, errorTTL time.Duration, loader func(context.Context, K) (V, errors.E),
+) (*LoadingLRUCache[K, V], errors.E) {
+	return NewLoadingLRUCacheWithOptions(size, errorSize, errorTTL, loader, LoadingLRUCacheOptions{
+		Clock: nil,
+	})
+}
+
+// LoadingLRUCacheOptions are options for NewLoadingLRUCacheWithOptions.
+type LoadingLRUCacheOptions struct {
+	// Clock to use for expiration of cached errors. If nil, SystemClock is used.
+	Clock Clock
+}
+
+// NewLoadingLRUCacheWithOptions is like NewLoadingLRUCache, but with options.
+func NewLoadingLRUCacheWithOptions[K comparable, V any](
+	size, errorSize int, errorTTL time.Duration, loader func(context.Context, K) (V, errors.E), options LoadingLRUCacheOptions,
+) (*LoadingLRUCache[K, V], errors.E) {
+	clock := options.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	cache, errE := NewLRUCache[K, V](size)
+	if errE != nil {
+		return nil, errE
+	}
+	errorCache, err := lru.New[K, cachedError](errorSize)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &LoadingLRUCache[K, V]{
+		LRUCache:      cache,
+		errorCache:    errorCache,
+		errorTTL:      errorTTL,
+		loader:        loader,
+		clock:         clock,
+		errorHitCount: 0,
+	}, nil
+}
+
+// Load retrieves a value from the cache. If the value is not in the cache,
+// it is loaded using the loader and stored into the cache.
+//
+// If the loader returns an error, the error is cached and returned for the
+// same key until it expires, without calling the loader again. The cached error
+// is returned as-is, with its details and stack trace.
+func (c *LoadingLRUCache[K, V]) Load(ctx context.Context, key K) (V, errors.E) { //nolint:ireturn
+	// We use the embedded lru.Cache directly to not count a miss
+	// before we checked the error cache.
+	value, ok := c.Cache.Get(key)
+	if ok {
+		return value, nil
+	}
+
+	cached, ok := c.errorCache.Get(key)
+	if ok {
+		if c.clock.Now().Before(cached.expires) {
+			atomic.AddUint64(&c.errorHitCount, 1)
+			return *new(V), cached.err
+		}
+		c.errorCache.Remove(key)
+	}
+
+	atomic.AddUint64(&c.missCount, 1)
+
+	value, errE := c.loader(ctx, key)
+	if errE != nil {
+		// We do not cache context errors because they are not a property of the key.
+		if !errors.Is(errE, context.Canceled) && !errors.Is(errE, context.DeadlineExceeded) {
+			c.errorCache.Add(key, cachedError{
+				err:     errE,
+				expires: c.clock.Now().Add(c.errorTTL),
+			})
+		}
+		return *new(V), errE
+	}
+
+	c.Add(key, value)
+	return value, nil
+}
+
+// Forget removes both the value and the cached error for the key.
+func (c *LoadingLRUCache[K, V]) Forget(key K) {
+	c.Remove(key)
+	c.errorCache.Remove(key)
+}
+
+// ErrorHitCount returns the number of requests answered with a cached
+// error since the last call of ErrorHitCount (or since the initialization
+// of the cache).
+func (c *LoadingLRUCache[K, V]) ErrorHitCount() uint64 {
+	return atomic.SwapUint64(&c.errorHitCount, 0)
+}
+
+// ErrorLen returns the number of cached errors, including those which
+// have expired but have not yet been removed.
+func (c *LoadingLRUCache[K, V]) ErrorLen() int {
+	return c.errorCache.Len()
+}
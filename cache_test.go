@@ -1,11 +1,14 @@
 package x_test
 
 import (
+	"context"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gitlab.com/tozd/go/errors"
 
 	"gitlab.com/tozd/go/x"
 )
@@ -126,3 +129,137 @@ func TestLRUCacheConcurrentMissCount(t *testing.T) {
 
 	assert.Equal(t, uint64(goroutines*misses), cache.MissCount())
 }
+
+var errTestLoader = errors.Base("test loader error")
+
+func TestLoadingLRUCache(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	cache, errE := x.NewLoadingLRUCache(10, 10, time.Hour, func(_ context.Context, key string) (int, errors.E) {
+		calls++
+		if key == "missing" {
+			return 0, errors.WithDetails(errTestLoader, "key", key)
+		}
+		return len(key), nil
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	val, errE := cache.Load(t.Context(), "abc")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, 3, val)
+	assert.Equal(t, 1, calls)
+
+	// Value hit.
+	val, errE = cache.Load(t.Context(), "abc")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, 3, val)
+	assert.Equal(t, 1, calls)
+
+	_, errE = cache.Load(t.Context(), "missing")
+	require.Error(t, errE)
+	assert.ErrorIs(t, errE, errTestLoader)
+	assert.Equal(t, 2, calls)
+
+	// Error hit keeps details and does not call the loader.
+	_, errE2 := cache.Load(t.Context(), "missing")
+	require.Error(t, errE2)
+	assert.ErrorIs(t, errE2, errTestLoader)
+	assert.Equal(t, "missing", errors.Details(errE2)["key"])
+	assert.Equal(t, errE.StackTrace(), errE2.StackTrace()) //nolint:errorlint
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 1, cache.ErrorLen())
+
+	assert.Equal(t, uint64(2), cache.MissCount())
+	assert.Equal(t, uint64(1), cache.ErrorHitCount())
+	assert.Equal(t, uint64(0), cache.ErrorHitCount())
+
+	cache.Forget("missing")
+	assert.Equal(t, 0, cache.ErrorLen())
+	_, errE = cache.Load(t.Context(), "missing")
+	require.Error(t, errE)
+	assert.Equal(t, 3, calls)
+}
+
+func TestLoadingLRUCacheErrorTTL(t *testing.T) {
+	t.Parallel()
+
+	clock := x.NewManualClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	calls := 0
+	cache, errE := x.NewLoadingLRUCacheWithOptions(10, 10, time.Minute, func(_ context.Context, _ int) (int, errors.E) {
+		calls++
+		return 0, errors.WithStack(errTestLoader)
+	}, x.LoadingLRUCacheOptions{Clock: clock})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	_, errE = cache.Load(t.Context(), 1)
+	require.Error(t, errE)
+	_, errE = cache.Load(t.Context(), 1)
+	require.Error(t, errE)
+	assert.Equal(t, 1, calls)
+
+	// Just before the expiration the cached error is still used.
+	clock.Advance(time.Minute - time.Nanosecond)
+	_, errE = cache.Load(t.Context(), 1)
+	require.Error(t, errE)
+	assert.Equal(t, 1, calls)
+
+	clock.Advance(time.Nanosecond)
+
+	// The cached error has expired, so the loader is called again.
+	_, errE = cache.Load(t.Context(), 1)
+	require.Error(t, errE)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, uint64(2), cache.MissCount())
+	assert.Equal(t, uint64(2), cache.ErrorHitCount())
+}
+
+func TestLoadingLRUCacheErrorSize(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	cache, errE := x.NewLoadingLRUCache(10, 2, time.Hour, func(_ context.Context, _ int) (int, errors.E) {
+		calls++
+		return 0, errors.WithStack(errTestLoader)
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	for i := range 3 {
+		_, errE = cache.Load(t.Context(), i)
+		require.Error(t, errE)
+	}
+	assert.Equal(t, 2, cache.ErrorLen())
+	assert.Equal(t, 3, calls)
+
+	// Error for key 0 was evicted.
+	_, errE = cache.Load(t.Context(), 0)
+	require.Error(t, errE)
+	assert.Equal(t, 4, calls)
+}
+
+func TestLoadingLRUCacheContextError(t *testing.T) {
+	t.Parallel()
+
+	cache, errE := x.NewLoadingLRUCache(10, 10, time.Hour, func(ctx context.Context, _ int) (int, errors.E) {
+		return 0, errors.WithStack(ctx.Err())
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, errE = cache.Load(ctx, 1)
+	require.Error(t, errE)
+	assert.ErrorIs(t, errE, context.Canceled)
+	assert.Equal(t, 0, cache.ErrorLen())
+}
+
+func TestNewLoadingLRUCacheInvalidSize(t *testing.T) {
+	t.Parallel()
+
+	cache, errE := x.NewLoadingLRUCache(10, 0, time.Hour, func(_ context.Context, _ int) (int, errors.E) {
+		return 0, nil
+	})
+	assert.Error(t, errE)
+	assert.Nil(t, cache)
+}
@@ -0,0 +1,159 @@
+package x
+
+import (
+	"sync"
+	"time"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// Clock provides the current time and tickers. Use SystemClock for
+// the real time and ManualClock in tests to control time deterministically.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTicker returns a new ClockTicker which sends the current time on
+	// its channel after each tick, with a period of d. It panics if d is not positive.
+	NewTicker(d time.Duration) ClockTicker
+}
+
+// ClockTicker is a ticker created by Clock. It behaves like [time.Ticker].
+type ClockTicker interface {
+	// Chan returns the channel on which ticks are delivered.
+	Chan() <-chan time.Time
+
+	// Stop turns off the ticker. After Stop, no more ticks will be sent.
+	Stop()
+}
+
+// SystemClock is a Clock using the real time from the time package.
+type SystemClock struct{}
+
+// Now implements Clock interface for SystemClock.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTicker implements Clock interface for SystemClock.
+func (SystemClock) NewTicker(d time.Duration) ClockTicker { //nolint:ireturn
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) Chan() <-chan time.Time {
+	return t.C
+}
+
+// ManualClock is a Clock whose time changes only when Advance or Set is called.
+// Tickers fire synchronously inside Advance and Set, so tests do not have to sleep.
+//
+// Like with [time.Ticker], ticker channels have a buffer of one tick and
+// ticks are dropped when a reader does not keep up.
+//
+// The zero value for a ManualClock is not usable. Use NewManualClock.
+type ManualClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	tickers map[*manualTicker]struct{}
+}
+
+// NewManualClock returns a new ManualClock with its current time set to now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{
+		lock:    sync.Mutex{},
+		now:     now,
+		tickers: map[*manualTicker]struct{}{},
+	}
+}
+
+// Now implements Clock interface for ManualClock.
+func (c *ManualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+// NewTicker implements Clock interface for ManualClock.
+func (c *ManualClock) NewTicker(d time.Duration) ClockTicker { //nolint:ireturn
+	if d <= 0 {
+		panic(errors.New("non-positive interval for ManualClock.NewTicker"))
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := &manualTicker{
+		clock:  c,
+		period: d,
+		next:   c.now.Add(d),
+		c:      make(chan time.Time, 1),
+	}
+	c.tickers[t] = struct{}{}
+	return t
+}
+
+// Advance moves the current time forward by d and fires all ticks
+// which are due by the new current time.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.set(c.now.Add(d))
+}
+
+// Set sets the current time to now and fires all ticks which are due by then.
+// Setting the time backwards does not fire any ticks.
+func (c *ManualClock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.set(now)
+}
+
+// Tickers returns the number of active (not stopped) tickers.
+func (c *ManualClock) Tickers() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.tickers)
+}
+
+func (c *ManualClock) set(now time.Time) {
+	c.now = now
+	for t := range c.tickers {
+		if t.next.After(now) {
+			continue
+		}
+		select {
+		case t.c <- t.next:
+		default:
+			// Drop the tick, like time.Ticker does.
+		}
+		// Any further ticks which are due are dropped as well because
+		// the channel buffer has space for only one tick.
+		t.next = t.next.Add((now.Sub(t.next)/t.period + 1) * t.period)
+	}
+}
+
+type manualTicker struct {
+	clock  *ManualClock
+	period time.Duration
+	next   time.Time
+	c      chan time.Time
+}
+
+func (t *manualTicker) Chan() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTicker) Stop() {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	delete(t.clock.tickers, t)
+}
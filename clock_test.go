@@ -0,0 +1,90 @@
+package x_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/tozd/go/x"
+)
+
+func TestSystemClock(t *testing.T) {
+	t.Parallel()
+
+	var clock x.Clock = x.SystemClock{}
+
+	before := time.Now()
+	now := clock.Now()
+	assert.False(t, now.Before(before))
+
+	ticker := clock.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	tick := <-ticker.Chan()
+	assert.True(t, tick.After(before))
+}
+
+func TestManualClock(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := x.NewManualClock(started)
+
+	assert.Equal(t, started, clock.Now())
+	clock.Advance(time.Minute)
+	assert.Equal(t, started.Add(time.Minute), clock.Now())
+	clock.Set(started)
+	assert.Equal(t, started, clock.Now())
+
+	ticker := clock.NewTicker(time.Second)
+	assert.Equal(t, 1, clock.Tickers())
+
+	// Not due yet.
+	clock.Advance(999 * time.Millisecond)
+	assertNoTick(t, ticker)
+
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, started.Add(time.Second), <-ticker.Chan())
+	assertNoTick(t, ticker)
+
+	// Only one tick is buffered, the rest are dropped.
+	clock.Advance(time.Hour + 500*time.Millisecond)
+	assert.Equal(t, started.Add(2*time.Second), <-ticker.Chan())
+	assertNoTick(t, ticker)
+
+	// Ticks continue on the original schedule.
+	clock.Advance(500 * time.Millisecond)
+	assert.Equal(t, started.Add(time.Hour+2*time.Second), <-ticker.Chan())
+
+	// Setting the time backwards does not fire.
+	clock.Set(started)
+	assertNoTick(t, ticker)
+	clock.Set(started.Add(time.Hour + 3*time.Second))
+	assert.Equal(t, started.Add(time.Hour+3*time.Second), <-ticker.Chan())
+
+	other := clock.NewTicker(time.Minute)
+	assert.Equal(t, 2, clock.Tickers())
+
+	ticker.Stop()
+	assert.Equal(t, 1, clock.Tickers())
+	clock.Advance(time.Minute)
+	assertNoTick(t, ticker)
+	assert.Equal(t, started.Add(time.Hour+time.Minute+3*time.Second), <-other.Chan())
+
+	other.Stop()
+	assert.Equal(t, 0, clock.Tickers())
+
+	assert.Panics(t, func() {
+		clock.NewTicker(0)
+	})
+}
+
+func assertNoTick(t *testing.T, ticker x.ClockTicker) {
+	t.Helper()
+
+	select {
+	case tick := <-ticker.Chan():
+		assert.Fail(t, "unexpected tick", "%v", tick)
+	default:
+	}
+}
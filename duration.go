@@ -0,0 +1,227 @@
+package x
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+var (
+	ErrInvalidDuration    = errors.Base("invalid duration")
+	ErrAmbiguousDuration  = errors.Base("ambiguous duration")
+	ErrDurationOutOfRange = errors.Base("duration out of range")
+)
+
+// DurationFormat is a format used by FormatDuration.
+type DurationFormat int
+
+const (
+	// DurationFormatGo formats durations as Duration.String does, e.g., "1h30m0s".
+	DurationFormatGo DurationFormat = iota
+	// DurationFormatISO8601 formats durations as ISO 8601 durations with only
+	// hours, minutes, and seconds, e.g., "PT1H30M". Days are not used.
+	DurationFormatISO8601
+)
+
+// DurationOptions are options for ParseDurationWithOptions.
+type DurationOptions struct {
+	// Strict rejects units without a fixed length: years and months in ISO 8601 durations.
+	Strict bool
+}
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+
+	// Nominal lengths of years and months in ISO 8601 durations, when not strict.
+	nominalYear  = 365 * day
+	nominalMonth = 30 * day
+)
+
+//nolint:gochecknoglobals
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond, // U+00B5 micro sign.
+	"μs": time.Microsecond, // U+03BC Greek letter mu.
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  day,
+	"w":  week,
+}
+
+// durationComponentRegexp matches one component of a duration in Go syntax, extended with days and weeks.
+//
+//nolint:gochecknoglobals
+var durationComponentRegexp = regexp.MustCompile(`^([0-9]*(?:\.[0-9]*)?)(ns|us|µs|μs|ms|s|m|h|d|w)`)
+
+// durationNumberRegexp matches an unsigned decimal number with an optional exponent.
+//
+//nolint:gochecknoglobals
+var durationNumberRegexp = regexp.MustCompile(`^[0-9]+(?:\.[0-9]*)?(?:[eE][+-]?[0-9]+)?$`)
+
+// durationISO8601Regexp matches an unsigned ISO 8601 duration, with upper case designators
+// and a decimal point.
+//
+//nolint:gochecknoglobals
+var durationISO8601Regexp = regexp.MustCompile(
+	`^P(?:([0-9.]+)Y)?(?:([0-9.]+)M)?(?:([0-9.]+)W)?(?:([0-9.]+)D)?(?:T(?:([0-9.]+)H)?(?:([0-9.]+)M)?(?:([0-9.]+)S)?)?$`,
+)
+
+// durationISO8601Units are units of durationISO8601Regexp groups.
+//
+//nolint:gochecknoglobals
+var durationISO8601Units = []time.Duration{nominalYear, nominalMonth, week, day, time.Hour, time.Minute, time.Second}
+
+// ParseDuration is like ParseDurationWithOptions with default options.
+func ParseDuration(s string) (time.Duration, errors.E) {
+	return ParseDurationWithOptions(s, DurationOptions{
+		Strict: false,
+	})
+}
+
+// ParseDurationWithOptions parses s as a duration in one of the following forms,
+// each with an optional sign:
+//
+//   - Go syntax as supported by time.ParseDuration, extended with "d" (24 hours)
+//     and "w" (7 days) units, e.g., "1d12h" or "1.5w".
+//   - ISO 8601 duration, e.g., "PT1H30M" or "P1DT12H". Days are 24 hours and weeks are 7 days.
+//     When not strict, years are 365 days and months are 30 days, otherwise
+//     they are rejected.
+//   - A number of seconds, e.g., "90" or "1.5".
+//
+// Fractions of nanoseconds are rounded to the nearest nanosecond.
+func ParseDurationWithOptions(s string, options DurationOptions) (time.Duration, errors.E) {
+	rest := s
+	negative := false
+	if strings.HasPrefix(rest, "-") || strings.HasPrefix(rest, "+") {
+		negative = rest[0] == '-'
+		rest = rest[1:]
+	}
+
+	var ns *big.Rat
+	var errE errors.E
+	switch {
+	case strings.HasPrefix(rest, "P") || strings.HasPrefix(rest, "p"):
+		ns, errE = parseDurationISO8601(rest, options)
+	case durationNumberRegexp.MatchString(rest):
+		var ok bool
+		ns, ok = new(big.Rat).SetString(rest)
+		if !ok {
+			errE = errors.WithStack(ErrInvalidDuration)
+		} else {
+			ns.Mul(ns, new(big.Rat).SetInt64(int64(time.Second)))
+		}
+	default:
+		ns, errE = parseDurationGo(rest)
+	}
+	if errE != nil {
+		errors.Details(errE)["duration"] = s
+		return 0, errE
+	}
+
+	if negative {
+		ns.Neg(ns)
+	}
+	ns, _ = RoundRat(ns, 0, RoundHalfEven)
+	if !ns.Num().IsInt64() {
+		return 0, errors.WithDetails(ErrDurationOutOfRange, "duration", s)
+	}
+	return time.Duration(ns.Num().Int64()), nil
+}
+
+// parseDurationGo parses s in Go syntax, extended with days and weeks,
+// and returns the number of nanoseconds.
+func parseDurationGo(s string) (*big.Rat, errors.E) {
+	if s == "" {
+		return nil, errors.WithStack(ErrInvalidDuration)
+	}
+
+	ns := new(big.Rat)
+	for s != "" {
+		match := durationComponentRegexp.FindStringSubmatch(s)
+		if match == nil || match[1] == "" || match[1] == "." {
+			return nil, errors.WithStack(ErrInvalidDuration)
+		}
+		value, _ := new(big.Rat).SetString(match[1])
+		ns.Add(ns, value.Mul(value, new(big.Rat).SetInt64(int64(durationUnits[match[2]]))))
+		s = s[len(match[0]):]
+	}
+	return ns, nil
+}
+
+// parseDurationISO8601 parses s as an ISO 8601 duration and returns the number of nanoseconds.
+func parseDurationISO8601(s string, options DurationOptions) (*big.Rat, errors.E) {
+	// ISO 8601 allows also a comma as the decimal sign.
+	s = strings.ReplaceAll(strings.ToUpper(s), ",", ".")
+	match := durationISO8601Regexp.FindStringSubmatch(s)
+	if match == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return nil, errors.WithStack(ErrInvalidDuration)
+	}
+
+	ns := new(big.Rat)
+	for i, value := range match[1:] {
+		if value == "" {
+			continue
+		}
+		if options.Strict && (durationISO8601Units[i] == nominalYear || durationISO8601Units[i] == nominalMonth) {
+			return nil, errors.WithStack(ErrAmbiguousDuration)
+		}
+		if !durationNumberRegexp.MatchString(value) {
+			return nil, errors.WithStack(ErrInvalidDuration)
+		}
+		v, _ := new(big.Rat).SetString(value)
+		ns.Add(ns, v.Mul(v, new(big.Rat).SetInt64(int64(durationISO8601Units[i]))))
+	}
+	return ns, nil
+}
+
+// FormatDuration formats d in the given format.
+func FormatDuration(d time.Duration, format DurationFormat) string {
+	if format == DurationFormatISO8601 {
+		return formatDurationISO8601(d)
+	}
+	return d.String()
+}
+
+func formatDurationISO8601(d time.Duration) string {
+	var b strings.Builder
+
+	// We use uint64 so that math.MinInt64 can be negated.
+	u := uint64(d) //nolint:gosec
+	if d < 0 {
+		b.WriteString("-")
+		u = -u
+	}
+	b.WriteString("PT")
+
+	hours := u / uint64(time.Hour)
+	u -= hours * uint64(time.Hour)
+	minutes := u / uint64(time.Minute)
+	u -= minutes * uint64(time.Minute)
+
+	if hours > 0 {
+		b.WriteString(strconv.FormatUint(hours, 10)) //nolint:mnd
+		b.WriteString("H")
+	}
+	if minutes > 0 {
+		b.WriteString(strconv.FormatUint(minutes, 10)) //nolint:mnd
+		b.WriteString("M")
+	}
+	if u > 0 || (hours == 0 && minutes == 0) {
+		b.WriteString(strconv.FormatUint(u/uint64(time.Second), 10)) //nolint:mnd
+		if fraction := u % uint64(time.Second); fraction > 0 {
+			b.WriteString(".")
+			b.WriteString(strings.TrimRight(strconv.FormatUint(fraction+uint64(time.Second), 10)[1:], "0")) //nolint:mnd
+		}
+		b.WriteString("S")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package x_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/tozd/go/x"
+)
+
+func TestParseDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		// Go syntax.
+		{"0", 0},
+		{"1h30m", 90 * time.Minute},
+		{"-1.5h", -90 * time.Minute},
+		{"+5s", 5 * time.Second},
+		{"1h1m1s1ms1us1µs1μs1ns", time.Hour + time.Minute + time.Second + time.Millisecond + 2*time.Microsecond + time.Microsecond + time.Nanosecond},
+		{".5s", 500 * time.Millisecond},
+		{"1.s", time.Second},
+		{"2562047h47m16.854775807s", math.MaxInt64},
+		{"-2562047h47m16.854775808s", math.MinInt64},
+		// Days and weeks.
+		{"1d", 24 * time.Hour},
+		{"1w2d3h", 9*24*time.Hour + 3*time.Hour},
+		{"1.5w", 252 * time.Hour},
+		// Numbers of seconds.
+		{"90", 90 * time.Second},
+		{"1.5", 1500 * time.Millisecond},
+		{"-0.25", -250 * time.Millisecond},
+		{"1e3", 1000 * time.Second},
+		{"0.0000000015", 2 * time.Nanosecond},
+		// ISO 8601.
+		{"PT1H30M", 90 * time.Minute},
+		{"P1D", 24 * time.Hour},
+		{"P1DT12H", 36 * time.Hour},
+		{"P2W", 14 * 24 * time.Hour},
+		{"PT0S", 0},
+		{"PT0.5S", 500 * time.Millisecond},
+		{"PT0,5S", 500 * time.Millisecond},
+		{"PT1.5M", 90 * time.Second},
+		{"-PT1H", -time.Hour},
+		{"pt1h", time.Hour},
+		{"P1Y", 365 * 24 * time.Hour},
+		{"P1M", 30 * 24 * time.Hour},
+		{"P1Y2M3DT4H5M6S", (365+60+3)*24*time.Hour + 4*time.Hour + 5*time.Minute + 6*time.Second},
+	}
+	for _, tt := range tests {
+		d, errE := x.ParseDuration(tt.value)
+		require.NoError(t, errE, "%s: % -+#.1v", tt.value, errE)
+		assert.Equal(t, tt.expected, d, tt.value)
+	}
+}
+
+func TestParseDurationErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{
+		"", "-", "+", "h", "1x", "1mo", "1h-1m", ".s", "1..5s", "--1s", " 1s", "0x10",
+		"P", "PT", "P1DT", "PT1D", "P1H", "P1S1M", "P.5D", "P1e3D", "P1DT1H1H",
+	} {
+		_, errE := x.ParseDuration(s)
+		assert.ErrorIs(t, errE, x.ErrInvalidDuration, s)
+	}
+
+	for _, s := range []string{"2562048h", "-2562047h47m16.854775809s", "1e20", "P300000Y"} {
+		_, errE := x.ParseDuration(s)
+		assert.ErrorIs(t, errE, x.ErrDurationOutOfRange, s)
+	}
+
+	for _, s := range []string{"P1Y", "P1M", "P1Y2M", "-P0M"} {
+		_, errE := x.ParseDurationWithOptions(s, x.DurationOptions{Strict: true})
+		assert.ErrorIs(t, errE, x.ErrAmbiguousDuration, s)
+	}
+
+	for _, s := range []string{"PT1M", "P1W", "P1D", "1d", "1w", "60"} {
+		_, errE := x.ParseDurationWithOptions(s, x.DurationOptions{Strict: true})
+		assert.NoError(t, errE, s)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   time.Duration
+		golang  string
+		iso8601 string
+	}{
+		{0, "0s", "PT0S"},
+		{time.Nanosecond, "1ns", "PT0.000000001S"},
+		{1500 * time.Millisecond, "1.5s", "PT1.5S"},
+		{90 * time.Minute, "1h30m0s", "PT1H30M"},
+		{36*time.Hour + 5*time.Second, "36h0m5s", "PT36H5S"},
+		{-time.Minute - 10*time.Millisecond, "-1m0.01s", "-PT1M0.01S"},
+		{math.MaxInt64, "2562047h47m16.854775807s", "PT2562047H47M16.854775807S"},
+		{math.MinInt64, "-2562047h47m16.854775808s", "-PT2562047H47M16.854775808S"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.golang, x.FormatDuration(tt.value, x.DurationFormatGo))
+		assert.Equal(t, tt.iso8601, x.FormatDuration(tt.value, x.DurationFormatISO8601))
+
+		for _, s := range []string{tt.golang, tt.iso8601} {
+			d, errE := x.ParseDurationWithOptions(s, x.DurationOptions{Strict: true})
+			require.NoError(t, errE, "%s: % -+#.1v", s, errE)
+			assert.Equal(t, tt.value, d, s)
+		}
+	}
+}
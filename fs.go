@@ -3,9 +3,14 @@ package x
 import (
 	"io"
 	"io/fs"
+	"os"
 	"path"
+	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	"gitlab.com/tozd/go/errors"
 )
 
@@ -68,6 +73,11 @@ func (f emptyFS) Sub(dir string) (fs.FS, error) {
 type FilteredFS struct {
 	fs      fs.FS
 	exclude []string
+
+	// ignore matches paths ignored by gitignore patterns. Paths are matched
+	// relative to the root of the original fs.FS, so prefix is prepended to them.
+	ignore gitignore.Matcher
+	prefix []string
 }
 
 var (
@@ -79,6 +89,33 @@ var (
 )
 
 func (f *FilteredFS) isExcluded(name string) bool {
+	if f.isExcludedPath(name) {
+		return true
+	}
+	if f.ignore == nil || name == "." {
+		return false
+	}
+	// Whether name is a directory matters only for directory-only patterns,
+	// so we can stat it lazily. Symbolic links are not directories for git.
+	isDir := func() bool {
+		info, err := fs.Lstat(f.fs, name)
+		return err == nil && info.IsDir()
+	}
+	return f.isIgnored(name, isDir)
+}
+
+// isExcludedEntry is like isExcluded but uses entry to determine if name is a directory.
+func (f *FilteredFS) isExcludedEntry(name string, entry fs.DirEntry) bool {
+	if f.isExcludedPath(name) {
+		return true
+	}
+	if f.ignore == nil {
+		return false
+	}
+	return f.isIgnored(name, entry.IsDir)
+}
+
+func (f *FilteredFS) isExcludedPath(name string) bool {
 	for _, excludePath := range f.exclude {
 		if name == excludePath || strings.HasPrefix(name, excludePath+"/") {
 			return true
@@ -87,6 +124,24 @@ func (f *FilteredFS) isExcluded(name string) bool {
 	return false
 }
 
+// isIgnored returns true if name or any of its parent directories is
+// ignored by gitignore patterns. Like git, a path cannot be re-included
+// by a negated pattern if its parent directory is ignored.
+func (f *FilteredFS) isIgnored(name string, isDir func() bool) bool {
+	parts := slices.Concat(f.prefix, strings.Split(name, "/"))
+	for i := len(f.prefix) + 1; i < len(parts); i++ {
+		if f.ignore.Match(parts[:i], true) {
+			return true
+		}
+	}
+	asFile := f.ignore.Match(parts, false)
+	asDir := f.ignore.Match(parts, true)
+	if asFile == asDir {
+		return asFile
+	}
+	return asDir == isDir()
+}
+
 // MakeFilteredFS creates a new FilteredFS.
 func MakeFilteredFS(fsys fs.FS, exclude ...string) (fs.FS, errors.E) {
 	if len(exclude) == 0 {
@@ -102,9 +157,153 @@ func MakeFilteredFS(fsys fs.FS, exclude ...string) (fs.FS, errors.E) {
 	return &FilteredFS{
 		fs:      fsys,
 		exclude: exclude,
+		ignore:  nil,
+		prefix:  nil,
+	}, nil
+}
+
+// GitIgnoreOptions are options for MakeGitIgnoreFS.
+type GitIgnoreOptions struct {
+	// GlobalExcludes enables reading of the global excludes file, configured
+	// with core.excludesFile in global git config, by default "$XDG_CONFIG_HOME/git/ignore".
+	GlobalExcludes bool
+
+	// Patterns are additional gitignore patterns, with the highest priority.
+	Patterns []string
+
+	// Exclude are additional paths to filter out, like for MakeFilteredFS.
+	Exclude []string
+}
+
+// MakeGitIgnoreFS creates a new FilteredFS which filters out paths ignored by git.
+//
+// It reads .gitignore files in all (not ignored) directories, ".git/info/exclude",
+// and optionally the global excludes file. Patterns are matched with priorities
+// like git does: patterns in .gitignore files in subdirectories override those
+// in parent directories, which override ".git/info/exclude", which overrides
+// the global excludes file. Negated and directory-only patterns are supported.
+// The ".git" directory is always filtered out.
+//
+// In linked worktrees and submodules ".git" is a file pointing to a git directory
+// elsewhere, outside of fsys, so ".git/info/exclude" is not read in that case.
+func MakeGitIgnoreFS(fsys fs.FS, options GitIgnoreOptions) (fs.FS, errors.E) {
+	for _, name := range options.Exclude {
+		if !fs.ValidPath(name) || name == "." {
+			return nil, errors.WithStack(&fs.PathError{Op: "filter", Path: name, Err: fs.ErrInvalid})
+		}
+	}
+
+	patterns := []gitignore.Pattern{}
+
+	if options.GlobalExcludes {
+		excludesFile, errE := gitGlobalExcludesFile()
+		if errE != nil {
+			return nil, errE
+		}
+		data, err := os.ReadFile(excludesFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.WithStack(err)
+		}
+		patterns = append(patterns, parseGitIgnore(data, nil)...)
+	}
+
+	info, err := fs.Stat(fsys, ".git")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.WithStack(err)
+	}
+	if info != nil && info.IsDir() {
+		data, err := fs.ReadFile(fsys, ".git/info/exclude")
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.WithStack(err)
+		}
+		patterns = append(patterns, parseGitIgnore(data, nil)...)
+	}
+
+	gitIgnorePatterns := []gitignore.Pattern{}
+	err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		domain := []string{}
+		if p != "." {
+			if d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			domain = strings.Split(p, "/")
+			// Like git, we do not read .gitignore files in ignored directories.
+			if gitignore.NewMatcher(slices.Concat(patterns, gitIgnorePatterns)).Match(domain, true) {
+				return fs.SkipDir
+			}
+		}
+		data, err := fs.ReadFile(fsys, path.Join(p, ".gitignore"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err //nolint:wrapcheck
+		}
+		gitIgnorePatterns = append(gitIgnorePatterns, parseGitIgnore(data, domain)...)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	patterns = append(patterns, gitIgnorePatterns...)
+
+	for _, pattern := range options.Patterns {
+		patterns = append(patterns, parseGitIgnore([]byte(pattern), nil)...)
+	}
+
+	return &FilteredFS{
+		fs:      fsys,
+		exclude: append([]string{".git"}, options.Exclude...),
+		ignore:  gitignore.NewMatcher(patterns),
+		prefix:  []string{},
 	}, nil
 }
 
+// parseGitIgnore parses gitignore patterns in data, one per line,
+// relative to the directory domain.
+func parseGitIgnore(data []byte, domain []string) []gitignore.Pattern {
+	patterns := []gitignore.Pattern{}
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+	return patterns
+}
+
+// gitGlobalExcludesFile returns the path to the global excludes file.
+func gitGlobalExcludesFile() (string, errors.E) {
+	cfg, err := config.LoadConfig(config.GlobalScope)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	excludesFile := cfg.Raw.Section("core").Option("excludesFile")
+	if excludesFile == "" {
+		configHome := os.Getenv("XDG_CONFIG_HOME")
+		if configHome == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", errors.WithStack(err)
+			}
+			configHome = filepath.Join(home, ".config")
+		}
+		return filepath.Join(configHome, "git", "ignore"), nil
+	}
+	if rest, ok := strings.CutPrefix(excludesFile, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		return filepath.Join(home, rest), nil
+	}
+	return excludesFile, nil
+}
+
 // filteredFile wraps an fs.File and filters ReadDir results.
 type filteredFile struct {
 	fs.File
@@ -133,7 +332,7 @@ func (f *filteredFile) ReadDir(n int) ([]fs.DirEntry, error) {
 		} else {
 			entryPath = path.Join(f.Name, entry.Name())
 		}
-		if !f.FilteredFS.isExcluded(entryPath) {
+		if !f.FilteredFS.isExcludedEntry(entryPath, entry) {
 			filtered = append(filtered, entry)
 		}
 	}
@@ -193,7 +392,7 @@ func (f *FilteredFS) ReadDir(name string) ([]fs.DirEntry, error) {
 		} else {
 			entryPath = path.Join(name, entry.Name())
 		}
-		if !f.isExcluded(entryPath) {
+		if !f.isExcludedEntry(entryPath, entry) {
 			filtered = append(filtered, entry)
 		}
 	}
@@ -283,9 +482,14 @@ func (f *FilteredFS) Sub(dir string) (fs.FS, error) {
 	}
 
 	// If there are no exclude paths in the subdirectory, return unwrapped.
-	if len(newExclude) == 0 {
+	if len(newExclude) == 0 && f.ignore == nil {
 		return sub, nil
 	}
 
-	return &FilteredFS{fs: sub, exclude: newExclude}, nil
+	var prefix []string
+	if f.ignore != nil {
+		prefix = slices.Concat(f.prefix, strings.Split(dir, "/"))
+	}
+
+	return &FilteredFS{fs: sub, exclude: newExclude, ignore: f.ignore, prefix: prefix}, nil
 }
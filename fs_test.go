@@ -3,6 +3,8 @@ package x_test
 import (
 	"io"
 	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
 	"testing/fstest"
 
@@ -806,3 +808,188 @@ func TestFilteredFS_NestedExclusions(t *testing.T) {
 		require.NoError(t, file.Close())
 	})
 }
+
+func createGitIgnoreTestFS() fstest.MapFS {
+	return fstest.MapFS{
+		".git/HEAD":          {Data: []byte("ref: refs/heads/main\n")},
+		".git/info/exclude":  {Data: []byte("# Local excludes.\nlocal.txt\n")},
+		".gitignore":         {Data: []byte("*.o\r\n!keep.o\nbuild/\n/root-only.txt\nlogs\n\n# Comment.\n")},
+		"main.c":             {Data: []byte("main")},
+		"main.o":             {Data: []byte("object")},
+		"keep.o":             {Data: []byte("keep")},
+		"root-only.txt":      {Data: []byte("root")},
+		"local.txt":          {Data: []byte("local")},
+		"logs":               {Data: []byte("logs")},
+		"build/out.bin":      {Data: []byte("out")},
+		"build/.gitignore":   {Data: []byte("!out.bin\n")},
+		"docs/logs/x.txt":    {Data: []byte("x")},
+		"docs/readme.md":     {Data: []byte("readme")},
+		"src/.gitignore":     {Data: []byte("!build/\ngenerated/\n!main.o\n")},
+		"src/main.c":         {Data: []byte("main")},
+		"src/main.o":         {Data: []byte("object")},
+		"src/other.o":        {Data: []byte("object")},
+		"src/root-only.txt":  {Data: []byte("not root")},
+		"src/build/out.txt":  {Data: []byte("out")},
+		"src/generated/a.go": {Data: []byte("generated")},
+		"src/generated.go":   {Data: []byte("not generated")},
+	}
+}
+
+func TestMakeGitIgnoreFS(t *testing.T) {
+	t.Parallel()
+
+	testFS := createGitIgnoreTestFS()
+
+	filtered, errE := x.MakeGitIgnoreFS(testFS, x.GitIgnoreOptions{GlobalExcludes: false, Patterns: nil, Exclude: nil})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	visible := []string{
+		".gitignore", "main.c", "keep.o", "docs/readme.md",
+		"src/.gitignore", "src/main.c", "src/main.o", "src/root-only.txt", "src/build/out.txt", "src/generated.go",
+	}
+	err := fstest.TestFS(filtered, visible...)
+	require.NoError(t, err)
+
+	for _, name := range []string{
+		".git/HEAD", "main.o", "root-only.txt", "local.txt", "logs", "build/out.bin",
+		"docs/logs/x.txt", "src/other.o", "src/generated/a.go",
+	} {
+		_, err := fs.ReadFile(filtered, name)
+		assert.ErrorIs(t, err, fs.ErrNotExist, name)
+		_, err = fs.Stat(filtered, name)
+		assert.ErrorIs(t, err, fs.ErrNotExist, name)
+	}
+
+	files := []string{}
+	err = fs.WalkDir(filtered, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, visible, files)
+
+	matches, err := fs.Glob(filtered, "*.o")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"keep.o"}, matches)
+
+	t.Run("sub", func(t *testing.T) {
+		t.Parallel()
+
+		sub, err := fs.Sub(filtered, "src")
+		require.NoError(t, err)
+
+		data, err := fs.ReadFile(sub, "build/out.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "out", string(data))
+
+		_, err = fs.ReadFile(sub, "other.o")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+
+		_, err = fs.ReadFile(sub, "generated/a.go")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+
+		sub, err = fs.Sub(sub, "build")
+		require.NoError(t, err)
+		data, err = fs.ReadFile(sub, "out.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "out", string(data))
+	})
+}
+
+func TestMakeGitIgnoreFSOptions(t *testing.T) {
+	t.Parallel()
+
+	testFS := createGitIgnoreTestFS()
+
+	filtered, errE := x.MakeGitIgnoreFS(testFS, x.GitIgnoreOptions{
+		GlobalExcludes: false,
+		Patterns:       []string{"*.md", "!main.o"},
+		Exclude:        []string{"src/build"},
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	_, err := fs.ReadFile(filtered, "docs/readme.md")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fs.ReadFile(filtered, "src/build/out.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	data, err := fs.ReadFile(filtered, "main.o")
+	require.NoError(t, err)
+	assert.Equal(t, "object", string(data))
+
+	_, errE = x.MakeGitIgnoreFS(testFS, x.GitIgnoreOptions{GlobalExcludes: false, Patterns: nil, Exclude: []string{"../invalid"}})
+	require.ErrorIs(t, errE, fs.ErrInvalid)
+}
+
+func TestMakeGitIgnoreFSGitFile(t *testing.T) {
+	t.Parallel()
+
+	// In linked worktrees and submodules .git is a file.
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".git"), []byte("gitdir: ../.git/modules/sub\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.o\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.c"), []byte("main"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.o"), []byte("object"), 0o600))
+
+	filtered, errE := x.MakeGitIgnoreFS(os.DirFS(dir), x.GitIgnoreOptions{GlobalExcludes: false, Patterns: nil, Exclude: nil})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	err := fstest.TestFS(filtered, ".gitignore", "main.c")
+	require.NoError(t, err)
+
+	for _, name := range []string{".git", "main.o"} {
+		_, err := fs.Stat(filtered, name)
+		assert.ErrorIs(t, err, fs.ErrNotExist, name)
+	}
+}
+
+func TestMakeGitIgnoreFSGlobalExcludes(t *testing.T) { //nolint:paralleltest
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	testFS := fstest.MapFS{
+		"file.txt":  {Data: []byte("file")},
+		"file.tmp":  {Data: []byte("tmp")},
+		"file.swp":  {Data: []byte("swp")},
+		".DS_Store": {Data: []byte("store")},
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".config", "git"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".config", "git", "ignore"), []byte("*.tmp\n"), 0o600))
+
+	filtered, errE := x.MakeGitIgnoreFS(testFS, x.GitIgnoreOptions{GlobalExcludes: true, Patterns: nil, Exclude: nil})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	entries, err := fs.ReadDir(filtered, ".")
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{".DS_Store", "file.swp", "file.txt"}, names)
+
+	// core.excludesFile overrides the default location.
+	require.NoError(t, os.WriteFile(filepath.Join(home, "excludes"), []byte("*.swp\n.DS_Store\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[core]\n\texcludesFile = ~/excludes\n"), 0o600))
+
+	filtered, errE = x.MakeGitIgnoreFS(testFS, x.GitIgnoreOptions{GlobalExcludes: true, Patterns: nil, Exclude: nil})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	entries, err = fs.ReadDir(filtered, ".")
+	require.NoError(t, err)
+	names = []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"file.tmp", "file.txt"}, names)
+
+	// Global excludes are not used by default.
+	filtered, errE = x.MakeGitIgnoreFS(testFS, x.GitIgnoreOptions{GlobalExcludes: false, Patterns: nil, Exclude: nil})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	entries, err = fs.ReadDir(filtered, ".")
+	require.NoError(t, err)
+	assert.Len(t, entries, 4)
+}
@@ -1,10 +1,24 @@
 package x
 
 import (
+	"container/heap"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	giturls "github.com/chainguard-dev/git-urls"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"gitlab.com/tozd/go/errors"
 )
 
@@ -12,40 +26,1483 @@ var (
 	ErrOpenGitRepository = errors.Base("cannot open git repository")
 	ErrObtainGitRemote   = errors.Base("cannot obtain git remote")
 	ErrParseGitRemoteURL = errors.Base("cannot parse git remote URL")
+	ErrNoCIEnvironment   = errors.Base("no CI environment variables")
 )
 
-// InferGitLabProjectID infers a GitLab project ID from "origin" remote of a
-// git repository at path.
-func InferGitLabProjectID(path string) (string, errors.E) {
+// Sources of GitRemote.
+const (
+	GitRemoteSourceGit                = "git"
+	GitRemoteSourceGitLabCI           = "gitlab-ci"
+	GitRemoteSourceGitHubActions      = "github-actions"
+	GitRemoteSourceBitbucketPipelines = "bitbucket-pipelines"
+)
+
+// GitForge is a type of a git hosting service.
+type GitForge string
+
+const (
+	GitForgeUnknown   GitForge = ""
+	GitForgeGitHub    GitForge = "github"
+	GitForgeGitLab    GitForge = "gitlab"
+	GitForgeGitea     GitForge = "gitea"
+	GitForgeBitbucket GitForge = "bitbucket"
+)
+
+// Well-known hosts of git hosting services.
+//
+//nolint:gochecknoglobals
+var gitForgeHosts = map[string]GitForge{
+	"github.com":    GitForgeGitHub,
+	"gitlab.com":    GitForgeGitLab,
+	"gitea.com":     GitForgeGitea,
+	"codeberg.org":  GitForgeGitea,
+	"bitbucket.org": GitForgeBitbucket,
+}
+
+// GitForgeInstance describes a self-hosted instance of a git hosting service.
+type GitForgeInstance struct {
+	// URL is the web base URL of the instance, e.g., "https://example.com/gitlab".
+	URL string
+
+	// Forge is the type of the instance.
+	Forge GitForge
+}
+
+// GitRemote describes a git remote and the project it points to.
+type GitRemote struct {
+	// Name is the name of the remote, e.g., "origin".
+	Name string `json:"name"`
+
+	// URL is the URL of the remote, with any credentials removed.
+	URL string `json:"url"`
+
+	// Scheme is the scheme of the remote URL, e.g., "ssh" or "https".
+	// SCP-style URLs (e.g., "git@gitlab.com:tozd/go/x.git") have scheme "ssh".
+	Scheme string `json:"scheme"`
+
+	// Host is the hostname of the remote, without the port.
+	Host string `json:"host"`
+
+	// Port is the port of the remote, if explicitly set in the remote URL.
+	Port string `json:"port,omitempty"`
+
+	// Namespace is the path of the project without its name and without any URL prefix
+	// of the instance. For GitLab it contains the group and all subgroups, e.g., "tozd/go".
+	Namespace string `json:"namespace,omitempty"`
+
+	// Project is the name of the project, e.g., "x".
+	Project string `json:"project"`
+
+	// WebURL is the web base URL of the git hosting service, e.g., "https://gitlab.com".
+	WebURL string `json:"webUrl,omitempty"`
+
+	// Forge is the detected type of the git hosting service.
+	Forge GitForge `json:"forge,omitempty"`
+
+	// SubmodulePath is the path of the submodule in the superproject,
+	// if the repository is a submodule.
+	SubmodulePath string `json:"submodulePath,omitempty"`
+
+	// Superproject is the remote of the superproject, if the repository is a submodule
+	// and the superproject's remote can be inferred.
+	Superproject *GitRemote `json:"superproject,omitempty"`
+
+	// Source is the source of information, GitRemoteSourceGit or one of
+	// CI environment sources (e.g., GitRemoteSourceGitLabCI).
+	Source string `json:"source"`
+}
+
+// ProjectPath returns the path of the project, the namespace and the name
+// of the project joined with "/", e.g., "tozd/go/x". For GitLab this is the
+// project ID which can be used with its API.
+func (r *GitRemote) ProjectPath() string {
+	if r.Namespace == "" {
+		return r.Project
+	}
+	return r.Namespace + "/" + r.Project
+}
+
+// ProjectURL returns the web URL of the project, e.g., "https://gitlab.com/tozd/go/x".
+// It returns an empty string if the web base URL is not known.
+func (r *GitRemote) ProjectURL() string {
+	if r.WebURL == "" {
+		return ""
+	}
+	return r.WebURL + "/" + r.ProjectPath()
+}
+
+// InferGitRemoteOptions are options for InferGitRemote.
+type InferGitRemoteOptions struct {
+	// Remote is the name of the remote to use. If empty, the remote is selected
+	// like git does: the remote configured for the current branch (its upstream),
+	// then "origin", and then the only remote if there is exactly one.
+	Remote string
+
+	// Push selects the push URL of the remote instead of the fetch URL. When selecting
+	// the remote, remote.pushDefault and the push remote configured for the current
+	// branch are considered first, too.
+	Push bool
+
+	// Instances are self-hosted instances of git hosting services. They are used
+	// to detect the type of the git hosting service and to determine its web base URL,
+	// which might include a URL prefix.
+	Instances []GitForgeInstance
+}
+
+// detectGitForge detects the type of the git hosting service from its hostname.
+func detectGitForge(host string) GitForge {
+	host = strings.ToLower(host)
+	if forge, ok := gitForgeHosts[host]; ok {
+		return forge
+	}
+	label, _, _ := strings.Cut(host, ".")
+	switch label {
+	case "github":
+		return GitForgeGitHub
+	case "gitlab":
+		return GitForgeGitLab
+	case "gitea", "forgejo":
+		return GitForgeGitea
+	case "bitbucket":
+		return GitForgeBitbucket
+	}
+	return GitForgeUnknown
+}
+
+// parseGitRemoteURL parses remote URL into GitRemote.
+func parseGitRemoteURL(name, rawURL string, instances []GitForgeInstance) (*GitRemote, errors.E) {
+	u, err := giturls.Parse(rawURL)
+	if err != nil {
+		errE := errors.WrapWith(err, ErrParseGitRemoteURL)
+		errors.Details(errE)["remote"] = name
+		errors.Details(errE)["url"] = rawURL
+		return nil, errE
+	}
+
+	// We remove any credentials.
+	u.User = nil
+
+	remote := &GitRemote{
+		Name:      name,
+		URL:       u.String(),
+		Scheme:    u.Scheme,
+		Host:      u.Hostname(),
+		Port:      u.Port(),
+		Namespace: "",
+		Project:   "",
+		WebURL:    "",
+		Forge:     GitForgeUnknown,
+
+		SubmodulePath: "",
+		Superproject:  nil,
+		Source:        GitRemoteSourceGit,
+	}
+
+	p := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+
+	matched := false
+	for _, instance := range instances {
+		instanceURL, err := url.Parse(instance.URL)
+		if err != nil {
+			errE := errors.WrapWith(err, ErrParseGitRemoteURL)
+			errors.Details(errE)["instance"] = instance.URL
+			return nil, errE
+		}
+		if !strings.EqualFold(instanceURL.Hostname(), remote.Host) {
+			continue
+		}
+		// For HTTP remotes we also require that the port matches. For SSH remotes
+		// the port is the port of the SSH server and not of the web server.
+		if (remote.Scheme == "http" || remote.Scheme == "https") && instanceURL.Port() != remote.Port {
+			continue
+		}
+		prefix := strings.Trim(instanceURL.Path, "/")
+		if prefix != "" {
+			rest, ok := strings.CutPrefix(p, prefix+"/")
+			if !ok {
+				// SSH remotes do not contain the prefix.
+				if remote.Scheme == "http" || remote.Scheme == "https" {
+					continue
+				}
+			} else {
+				p = rest
+			}
+		}
+		remote.WebURL = strings.TrimSuffix(instanceURL.String(), "/")
+		remote.Forge = instance.Forge
+		matched = true
+		break
+	}
+
+	if !matched && remote.Host != "" {
+		remote.Forge = detectGitForge(remote.Host)
+		webURL := url.URL{ //nolint:exhaustruct
+			Scheme: "https",
+			Host:   remote.Host,
+		}
+		if remote.Scheme == "http" || remote.Scheme == "https" {
+			webURL.Scheme = remote.Scheme
+			webURL.Host = u.Host
+		}
+		remote.WebURL = webURL.String()
+	}
+
+	if p == "" {
+		errE := errors.WithDetails(ErrParseGitRemoteURL, "remote", name)
+		errors.Details(errE)["url"] = remote.URL
+		return nil, errE
+	}
+
+	if i := strings.LastIndex(p, "/"); i != -1 {
+		remote.Namespace = p[:i]
+		remote.Project = p[i+1:]
+	} else {
+		remote.Project = p
+	}
+
+	return remote, nil
+}
+
+// gitURLRewrite is a url.<base>.insteadOf or url.<base>.pushInsteadOf rule.
+type gitURLRewrite struct {
+	base      string
+	insteadOf string
+}
+
+// gitURLRewrites returns url.<base>.insteadOf and url.<base>.pushInsteadOf
+// rules from all configs.
+func gitURLRewrites(configs []*config.Config) ([]gitURLRewrite, []gitURLRewrite) {
+	rewrites := []gitURLRewrite{}
+	pushRewrites := []gitURLRewrite{}
+	for _, cfg := range configs {
+		for _, subsection := range cfg.Raw.Section("url").Subsections {
+			for _, insteadOf := range subsection.OptionAll("insteadOf") {
+				rewrites = append(rewrites, gitURLRewrite{base: subsection.Name, insteadOf: insteadOf})
+			}
+			for _, insteadOf := range subsection.OptionAll("pushInsteadOf") {
+				pushRewrites = append(pushRewrites, gitURLRewrite{base: subsection.Name, insteadOf: insteadOf})
+			}
+		}
+	}
+	return rewrites, pushRewrites
+}
+
+// applyGitURLRewrites rewrites rawURL using the longest matching rule.
+// It returns false if no rule matched.
+func applyGitURLRewrites(rawURL string, rewrites []gitURLRewrite) (string, bool) {
+	var longest *gitURLRewrite
+	for i, rewrite := range rewrites {
+		if strings.HasPrefix(rawURL, rewrite.insteadOf) && (longest == nil || len(rewrite.insteadOf) > len(longest.insteadOf)) {
+			longest = &rewrites[i]
+		}
+	}
+	if longest == nil {
+		return rawURL, false
+	}
+	return longest.base + strings.TrimPrefix(rawURL, longest.insteadOf), true
+}
+
+// loadGitConfigs returns system, global, and local git configs, in that order.
+// Like git, it skips the system config if GIT_CONFIG_NOSYSTEM is set to a true value.
+func loadGitConfigs(repository *git.Repository) ([]*config.Config, error) {
+	system := config.NewConfig()
+	if noSystem, _ := strconv.ParseBool(os.Getenv("GIT_CONFIG_NOSYSTEM")); !noSystem {
+		var err error
+		system, err = config.LoadConfig(config.SystemScope)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+	}
+	global, err := config.LoadConfig(config.GlobalScope)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	local, err := repository.Config()
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return []*config.Config{system, global, local}, nil
+}
+
+// currentGitBranch returns the name of the current branch, or an empty string
+// if HEAD is detached.
+func currentGitBranch(repository *git.Repository) string {
+	head, err := repository.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return ""
+	}
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return ""
+	}
+	return head.Target().Short()
+}
+
+// selectGitRemote selects the name of the remote to use.
+func selectGitRemote(repository *git.Repository, local *config.Config, options InferGitRemoteOptions) (string, errors.E) {
+	if options.Remote != "" {
+		return options.Remote, nil
+	}
+
+	remotes := local.Raw.Section("remote")
+	branch := local.Raw.Section("branch").Subsection(currentGitBranch(repository))
+
+	candidates := []string{}
+	if options.Push {
+		candidates = append(candidates, branch.Option("pushRemote"), remotes.Option("pushDefault"))
+	}
+	candidates = append(candidates, branch.Option("remote"), "origin")
+	for _, candidate := range candidates {
+		// "." means the local repository.
+		if candidate != "" && candidate != "." && remotes.HasSubsection(candidate) {
+			return candidate, nil
+		}
+	}
+
+	if len(remotes.Subsections) == 1 {
+		return remotes.Subsections[0].Name, nil
+	}
+
+	names := []string{}
+	for _, subsection := range remotes.Subsections {
+		names = append(names, subsection.Name)
+	}
+	return "", errors.WithDetails(ErrObtainGitRemote, "remotes", names)
+}
+
+// openGitRepository opens a git repository at path. path can be a bare repository,
+// a worktree (including a linked worktree or a submodule), or any directory inside
+// a worktree.
+func openGitRepository(path string) (*git.Repository, errors.E) {
+	// We first try to open path directly, which supports bare repositories
+	// (DetectDotGit does not), and only then search parent directories.
 	repository, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
-		DetectDotGit:          true,
-		EnableDotGitCommonDir: false,
+		DetectDotGit:          false,
+		EnableDotGitCommonDir: true,
 	})
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repository, err = git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
+			DetectDotGit:          true,
+			EnableDotGitCommonDir: true,
+		})
+	}
 	if err != nil {
 		errE := errors.WrapWith(err, ErrOpenGitRepository)
 		errors.Details(errE)["path"] = path
-		return "", errE
+		return nil, errE
 	}
+	return repository, nil
+}
+
+// gitWorktreeRoot returns the root directory of the worktree of the repository,
+// or an empty string for bare repositories.
+func gitWorktreeRoot(repository *git.Repository) string {
+	worktree, err := repository.Worktree()
+	if err != nil {
+		return ""
+	}
+	return worktree.Filesystem.Root()
+}
+
+// findGitSuperproject returns the root directory of the superproject's worktree
+// and the path of the submodule in it, if the worktree at root is a submodule.
+func findGitSuperproject(root string) (string, string, bool) {
+	if root == "" {
+		return "", "", false
+	}
+	for dir := filepath.Dir(root); ; dir = filepath.Dir(dir) {
+		repository, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{
+			DetectDotGit:          false,
+			EnableDotGitCommonDir: true,
+		})
+		if err == nil {
+			superRoot := gitWorktreeRoot(repository)
+			if superRoot == "" {
+				return "", "", false
+			}
+			rel, err := filepath.Rel(superRoot, root)
+			if err != nil {
+				return "", "", false
+			}
+			rel = filepath.ToSlash(rel)
+			worktree, err := repository.Worktree()
+			if err != nil {
+				return "", "", false
+			}
+			submodules, err := worktree.Submodules()
+			if err != nil {
+				return "", "", false
+			}
+			for _, submodule := range submodules {
+				if path.Clean(submodule.Config().Path) == rel {
+					return superRoot, rel, true
+				}
+			}
+			// The closest repository is not a superproject of this one.
+			return "", "", false
+		}
+		if filepath.Dir(dir) == dir {
+			return "", "", false
+		}
+	}
+}
 
-	remote, err := repository.Remote("origin")
+// InferGitRemote infers information about a git remote of a git repository at path:
+// its host, the project it points to, and the git hosting service (forge) used.
+//
+// The remote is selected as configured in options, by default using the remote
+// configured for the current branch, "origin", or the only remote. Remote URL is
+// rewritten using url.<base>.insteadOf (and url.<base>.pushInsteadOf, when
+// Push is set in options) rules from git config, like git does.
+//
+// SCP-style (e.g., "git@gitlab.com:tozd/go/x.git"), SSH, and HTTP(S) remote URLs
+// are supported. Self-hosted instances of git hosting services, potentially under
+// a URL prefix, can be configured in options.
+//
+// path can be a bare repository, a worktree (including a linked worktree), or any
+// directory inside a worktree. If the repository is a submodule, the remote of the
+// superproject (selected like git does) is inferred as well.
+func InferGitRemote(path string, options InferGitRemoteOptions) (*GitRemote, errors.E) {
+	repository, errE := openGitRepository(path)
+	if errE != nil {
+		return nil, errE
+	}
+
+	configs, err := loadGitConfigs(repository)
 	if err != nil {
 		errE := errors.WrapWith(err, ErrObtainGitRemote)
 		errors.Details(errE)["path"] = path
-		errors.Details(errE)["remote"] = "origin"
+		return nil, errE
+	}
+	local := configs[len(configs)-1]
+
+	name, errE := selectGitRemote(repository, local, options)
+	if errE != nil {
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+
+	remote := local.Raw.Section("remote").Subsection(name)
+	urls := remote.OptionAll("url")
+	if len(urls) == 0 {
+		errE := errors.WithDetails(ErrObtainGitRemote, "path", path)
+		errors.Details(errE)["remote"] = name
+		return nil, errE
+	}
+
+	rewrites, pushRewrites := gitURLRewrites(configs)
+
+	rawURL := urls[0]
+	if pushURLs := remote.OptionAll("pushurl"); options.Push && len(pushURLs) > 0 {
+		// Rewrite rules are applied to push URLs as well, but not push rewrite rules.
+		rawURL, _ = applyGitURLRewrites(pushURLs[0], rewrites)
+	} else {
+		ok := false
+		if options.Push {
+			rawURL, ok = applyGitURLRewrites(rawURL, pushRewrites)
+		}
+		if !ok {
+			rawURL, _ = applyGitURLRewrites(rawURL, rewrites)
+		}
+	}
+
+	gitRemote, errE := parseGitRemoteURL(name, rawURL, options.Instances)
+	if errE != nil {
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+
+	if superRoot, submodulePath, ok := findGitSuperproject(gitWorktreeRoot(repository)); ok {
+		gitRemote.SubmodulePath = submodulePath
+		// The superproject's remote is best-effort: it is left nil if it cannot be inferred
+		// (e.g., the superproject has no remote), while the submodule's remote is still returned.
+		superproject, errE := InferGitRemote(superRoot, InferGitRemoteOptions{
+			Remote:    "",
+			Push:      options.Push,
+			Instances: options.Instances,
+		})
+		if errE == nil {
+			gitRemote.Superproject = superproject
+		}
+	}
+
+	return gitRemote, nil
+}
+
+// InferGitLabProjectID infers a GitLab project ID from the remote of a git
+// repository at path. The remote is selected like git does: the remote
+// configured for the current branch, "origin", or the only remote.
+func InferGitLabProjectID(path string) (string, errors.E) {
+	remote, errE := InferGitRemote(path, InferGitRemoteOptions{
+		Remote:    "",
+		Push:      false,
+		Instances: nil,
+	})
+	if errE != nil {
 		return "", errE
 	}
 
-	url, err := giturls.Parse(remote.Config().URLs[0])
+	return remote.ProjectPath(), nil
+}
+
+// gitRemoteFromCI constructs GitRemote for the project at projectPath
+// on the instance at serverURL.
+func gitRemoteFromCI(source, serverURL, projectPath string, forge GitForge) (*GitRemote, errors.E) {
+	serverURL = strings.TrimSuffix(serverURL, "/")
+	remote, errE := parseGitRemoteURL("", serverURL+"/"+strings.Trim(projectPath, "/")+".git", []GitForgeInstance{{
+		URL:   serverURL,
+		Forge: forge,
+	}})
+	if errE != nil {
+		errors.Details(errE)["source"] = source
+		return nil, errE
+	}
+	remote.Source = source
+	return remote, nil
+}
+
+// InferGitRemoteFromEnv infers information about the project from environment
+// variables set by CI systems. They are consulted in the following order:
+//
+//   - GitLab CI: CI_PROJECT_PATH and CI_SERVER_URL (defaults to "https://gitlab.com").
+//   - GitHub Actions: GITHUB_REPOSITORY and GITHUB_SERVER_URL (defaults to "https://github.com").
+//     If GITEA_ACTIONS or FORGEJO_ACTIONS is "true", the forge is Gitea.
+//   - Bitbucket Pipelines: BITBUCKET_REPO_FULL_NAME.
+//
+// Source of the returned GitRemote reports which CI system was used.
+// Name of the returned GitRemote is always empty.
+func InferGitRemoteFromEnv() (*GitRemote, errors.E) {
+	if projectPath := os.Getenv("CI_PROJECT_PATH"); projectPath != "" {
+		serverURL := os.Getenv("CI_SERVER_URL")
+		if serverURL == "" {
+			serverURL = "https://gitlab.com"
+		}
+		return gitRemoteFromCI(GitRemoteSourceGitLabCI, serverURL, projectPath, GitForgeGitLab)
+	}
+
+	if projectPath := os.Getenv("GITHUB_REPOSITORY"); projectPath != "" {
+		serverURL := os.Getenv("GITHUB_SERVER_URL")
+		if serverURL == "" {
+			serverURL = "https://github.com"
+		}
+		forge := GitForgeGitHub
+		if os.Getenv("GITEA_ACTIONS") == "true" || os.Getenv("FORGEJO_ACTIONS") == "true" {
+			forge = GitForgeGitea
+		}
+		return gitRemoteFromCI(GitRemoteSourceGitHubActions, serverURL, projectPath, forge)
+	}
+
+	if projectPath := os.Getenv("BITBUCKET_REPO_FULL_NAME"); projectPath != "" {
+		return gitRemoteFromCI(GitRemoteSourceBitbucketPipelines, "https://bitbucket.org", projectPath, GitForgeBitbucket)
+	}
+
+	return nil, errors.WithStack(ErrNoCIEnvironment)
+}
+
+// ResolveGitRemote infers information about the project of a git repository at path.
+//
+// It first uses InferGitRemote. If that fails (e.g., there is no git repository
+// at path, as in Docker builds, or the repository has no usable remote), it falls
+// back to InferGitRemoteFromEnv. If neither succeeds, the error from InferGitRemote
+// is returned.
+//
+// Source of the returned GitRemote reports where the information came from.
+func ResolveGitRemote(path string, options InferGitRemoteOptions) (*GitRemote, errors.E) {
+	remote, errE := InferGitRemote(path, options)
+	if errE == nil {
+		return remote, nil
+	}
+
+	remote, envErrE := InferGitRemoteFromEnv()
+	if envErrE == nil {
+		return remote, nil
+	} else if !errors.Is(envErrE, ErrNoCIEnvironment) {
+		return nil, envErrE
+	}
+
+	return nil, errE
+}
+
+// Sources of GitRevision.
+const (
+	GitRevisionSourceGit       = "git"
+	GitRevisionSourceBuildInfo = "buildinfo"
+)
+
+// gitShortHashLength is the length of abbreviated commit hashes, the same as git's default.
+const gitShortHashLength = 7
+
+// GitRevision describes the checked out commit of a git repository.
+type GitRevision struct {
+	// Commit is the full hash of the HEAD commit.
+	Commit string `json:"commit"`
+
+	// ShortCommit is the abbreviated hash of the HEAD commit.
+	ShortCommit string `json:"shortCommit"`
+
+	// Branch is the name of the current branch. It is empty if HEAD is detached
+	// or if it is not known.
+	Branch string `json:"branch,omitempty"`
+
+	// Tag is the name of the nearest tag reachable from the HEAD commit.
+	// It is empty if there is no such tag or if it is not known.
+	Tag string `json:"tag,omitempty"`
+
+	// TagDistance is the number of commits reachable from the HEAD commit
+	// which are not reachable from Tag. It is 0 if the HEAD commit is tagged with Tag.
+	TagDistance int `json:"tagDistance,omitempty"`
+
+	// Dirty is true if there are uncommitted changes to tracked files.
+	Dirty bool `json:"dirty,omitempty"`
+
+	// Time is the commit time of the HEAD commit.
+	Time time.Time `json:"time"`
+
+	// Source is the source of information, GitRevisionSourceGit or GitRevisionSourceBuildInfo.
+	Source string `json:"source"`
+}
+
+// Describe returns a description of the revision, similar to "git describe --tags --always --dirty",
+// e.g., "v1.2.0", "v1.2.0-3-g1a2b3c4", "v1.2.0-dirty", or "1a2b3c4".
+func (r *GitRevision) Describe() string {
+	var d string
+	switch {
+	case r.Tag == "":
+		d = r.ShortCommit
+	case r.TagDistance == 0:
+		d = r.Tag
+	default:
+		d = r.Tag + "-" + strconv.Itoa(r.TagDistance) + "-g" + r.ShortCommit
+	}
+	if r.Dirty {
+		d += "-dirty"
+	}
+	return d
+}
+
+// gitTags returns a map between commits and names of tags pointing to them.
+// If multiple tags point to the same commit, the last one by name is used.
+func gitTags(repository *git.Repository) (map[plumbing.Hash]string, error) {
+	tags := map[plumbing.Hash]string{}
+	iter, err := repository.Tags()
 	if err != nil {
-		errE := errors.WrapWith(err, ErrParseGitRemoteURL)
-		errors.Details(errE)["path"] = path
-		errors.Details(errE)["remote"] = "origin"
-		errors.Details(errE)["url"] = remote.Config().URLs[0]
+		return nil, err //nolint:wrapcheck
+	}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		// We peel annotated tags.
+		tag, err := repository.TagObject(hash)
+		if err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				// Tags pointing to objects other than commits are ignored.
+				return nil
+			}
+			hash = commit.Hash
+		} else if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return err //nolint:wrapcheck
+		}
+		name := ref.Name().Short()
+		if existing, ok := tags[hash]; !ok || name > existing {
+			tags[hash] = name
+		}
+		return nil
+	})
+	return tags, err //nolint:wrapcheck
+}
+
+// gitReachable returns all commits reachable from commit, including the commit itself.
+// Traversal stops at commits in stop, which are not included.
+func gitReachable(commit *object.Commit, stop map[plumbing.Hash]bool) (map[plumbing.Hash]bool, error) {
+	reachable := map[plumbing.Hash]bool{}
+	queue := []*object.Commit{commit}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if reachable[c.Hash] || stop[c.Hash] {
+			continue
+		}
+		reachable[c.Hash] = true
+		err := c.Parents().ForEach(func(parent *object.Commit) error {
+			queue = append(queue, parent)
+			return nil
+		})
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+	}
+	return reachable, nil
+}
+
+// gitDescribeCandidates is the number of most recent tags considered by nearestGitTag,
+// like the default of "git describe --candidates".
+const gitDescribeCandidates = 10
+
+// gitCommitQueueItem is an item in gitCommitQueue.
+type gitCommitQueueItem struct {
+	commit *object.Commit
+	seq    int
+}
+
+// gitCommitQueue is a priority queue of commits ordered by committer time, newest first,
+// and by insertion order for the same time. This is the order in which git walks history.
+type gitCommitQueue struct {
+	items []gitCommitQueueItem
+	seq   int
+}
+
+func (q *gitCommitQueue) Len() int {
+	return len(q.items)
+}
+
+func (q *gitCommitQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if !a.commit.Committer.When.Equal(b.commit.Committer.When) {
+		return a.commit.Committer.When.After(b.commit.Committer.When)
+	}
+	return a.seq < b.seq
+}
+
+func (q *gitCommitQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+}
+
+func (q *gitCommitQueue) Push(x any) {
+	q.items = append(q.items, x.(gitCommitQueueItem)) //nolint:forcetypeassert,errcheck
+}
+
+func (q *gitCommitQueue) Pop() any {
+	item := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return item
+}
+
+func (q *gitCommitQueue) push(commit *object.Commit) {
+	heap.Push(q, gitCommitQueueItem{commit: commit, seq: q.seq})
+	q.seq++
+}
+
+func (q *gitCommitQueue) pop() *object.Commit {
+	return heap.Pop(q).(gitCommitQueueItem).commit //nolint:forcetypeassert,errcheck
+}
+
+// nearestGitTag finds the nearest tagged commit reachable from any of commits,
+// and the number of commits reachable from commits but not from the tagged commit.
+//
+// Like "git describe", it walks history from commits newest first, considers the first
+// gitDescribeCandidates tagged commits found, and picks the one with the fewest commits
+// reachable from commits but not from it. Ties are resolved in favor of the one found first.
+// It returns nil if there is no such commit.
+func nearestGitTag(tags map[plumbing.Hash]string, commits []*object.Commit) (*object.Commit, int, error) {
+	reachable := map[plumbing.Hash]bool{}
+	candidates := []*object.Commit{}
+	queue := &gitCommitQueue{items: nil, seq: 0}
+	for _, c := range commits {
+		queue.push(c)
+	}
+	for queue.Len() > 0 {
+		c := queue.pop()
+		if reachable[c.Hash] {
+			continue
+		}
+		reachable[c.Hash] = true
+		if _, ok := tags[c.Hash]; ok && len(candidates) < gitDescribeCandidates {
+			candidates = append(candidates, c)
+		}
+		err := c.Parents().ForEach(func(parent *object.Commit) error {
+			queue.push(parent)
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err //nolint:wrapcheck
+		}
+	}
+
+	var nearest *object.Commit
+	distance := 0
+	for _, candidate := range candidates {
+		// All commits reachable from candidate are reachable from commits as well.
+		fromCandidate, err := gitReachable(candidate, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		d := len(reachable) - len(fromCandidate)
+		if nearest == nil || d < distance {
+			nearest = candidate
+			distance = d
+		}
+	}
+	return nearest, distance, nil
+}
+
+// describeGitCommit finds the nearest tag reachable from commit (see nearestGitTag),
+// and the number of commits reachable from commit but not from the tag.
+func describeGitCommit(repository *git.Repository, commit *object.Commit) (string, int, error) {
+	tags, err := gitTags(repository)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(tags) == 0 {
+		return "", 0, nil
+	}
+
+	tagged, distance, err := nearestGitTag(tags, []*object.Commit{commit})
+	if err != nil {
+		return "", 0, err
+	}
+	if tagged == nil {
+		return "", 0, nil
+	}
+
+	return tags[tagged.Hash], distance, nil
+}
+
+// isGitWorktreeDirty returns true if there are uncommitted changes to tracked files.
+// Bare repositories are never dirty.
+func isGitWorktreeDirty(repository *git.Repository) (bool, error) {
+	worktree, err := repository.Worktree()
+	if errors.Is(err, git.ErrIsBareRepository) {
+		return false, nil
+	} else if err != nil {
+		return false, err //nolint:wrapcheck
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return false, err //nolint:wrapcheck
+	}
+	for _, s := range status {
+		if s.Worktree == git.Untracked && s.Staging == git.Untracked {
+			continue
+		}
+		if s.Worktree != git.Unmodified || s.Staging != git.Unmodified {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GitInfo returns information about the checked out commit of a git repository at path:
+// its hash, the current branch, the nearest tag (like "git describe --tags"),
+// whether there are uncommitted changes, and the commit time.
+//
+// If there is no git repository at path, it falls back to VCS information
+// embedded into the binary by the Go toolchain (see GitInfoFromBuildInfo).
+// That information does not include the branch nor tags.
+func GitInfo(path string) (*GitRevision, errors.E) {
+	repository, errE := openGitRepository(path)
+	if errors.Is(errE, git.ErrRepositoryNotExists) {
+		info, errE2 := GitInfoFromBuildInfo()
+		if errE2 == nil {
+			return info, nil
+		}
+		return nil, errE
+	} else if errE != nil {
+		return nil, errE
+	}
+
+	head, err := repository.Head()
+	if err != nil {
+		return nil, errors.WithDetails(err, "path", path)
+	}
+	commit, err := repository.CommitObject(head.Hash())
+	if err != nil {
+		return nil, errors.WithDetails(err, "path", path)
+	}
+
+	tag, distance, err := describeGitCommit(repository, commit)
+	if err != nil {
+		return nil, errors.WithDetails(err, "path", path)
+	}
+
+	dirty, err := isGitWorktreeDirty(repository)
+	if err != nil {
+		return nil, errors.WithDetails(err, "path", path)
+	}
+
+	hash := commit.Hash.String()
+	return &GitRevision{
+		Commit:      hash,
+		ShortCommit: hash[:gitShortHashLength],
+		Branch:      currentGitBranch(repository),
+		Tag:         tag,
+		TagDistance: distance,
+		Dirty:       dirty,
+		Time:        commit.Committer.When.UTC(),
+		Source:      GitRevisionSourceGit,
+	}, nil
+}
+
+var ErrNoBuildInfo = errors.Base("no VCS build information")
+
+// GitInfoFromBuildInfo returns information about the commit from which the
+// running binary was built, as embedded by the Go toolchain (see debug.ReadBuildInfo).
+//
+// Only the commit hash, the commit time, and whether there were uncommitted
+// changes are available.
+func GitInfoFromBuildInfo() (*GitRevision, errors.E) {
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil, errors.WithStack(ErrNoBuildInfo)
+	}
+	return gitInfoFromBuildSettings(buildInfo.Settings)
+}
+
+func gitInfoFromBuildSettings(settings []debug.BuildSetting) (*GitRevision, errors.E) {
+	info := &GitRevision{
+		Commit:      "",
+		ShortCommit: "",
+		Branch:      "",
+		Tag:         "",
+		TagDistance: 0,
+		Dirty:       false,
+		Time:        time.Time{},
+		Source:      GitRevisionSourceBuildInfo,
+	}
+	vcs := ""
+	for _, setting := range settings {
+		switch setting.Key {
+		case "vcs":
+			vcs = setting.Value
+		case "vcs.revision":
+			info.Commit = setting.Value
+		case "vcs.time":
+			t, err := time.Parse(time.RFC3339Nano, setting.Value)
+			if err != nil {
+				return nil, errors.WithDetails(err, "time", setting.Value)
+			}
+			info.Time = t.UTC()
+		case "vcs.modified":
+			info.Dirty = setting.Value == "true"
+		}
+	}
+	if vcs != "git" || info.Commit == "" {
+		return nil, errors.WithDetails(ErrNoBuildInfo, "vcs", vcs)
+	}
+	info.ShortCommit = info.Commit
+	if len(info.ShortCommit) > gitShortHashLength {
+		info.ShortCommit = info.ShortCommit[:gitShortHashLength]
+	}
+	return info, nil
+}
+
+var (
+	ErrGitFileNotFound      = errors.Base("file not found in git commit")
+	ErrUnsupportedGitForge  = errors.Base("unsupported git forge")
+	ErrResolveGitRevision   = errors.Base("cannot resolve git revision")
+	ErrInvalidPermalinkLine = errors.Base("invalid permalink line")
+)
+
+// PermalinkOptions are options for PermalinkWithOptions.
+type PermalinkOptions struct {
+	// Ref is the git revision (e.g., a branch, a tag, or a commit hash) to link to.
+	// It is resolved to a commit hash. The default is HEAD.
+	Ref string
+
+	// Remote configures how the git remote is inferred.
+	Remote InferGitRemoteOptions
+}
+
+// Permalink returns a web URL of the file at filePath in a git repository at repoPath,
+// as of the HEAD commit, highlighting lines from lineStart to lineEnd (inclusive).
+//
+// See PermalinkWithOptions for details.
+func Permalink(repoPath, filePath string, lineStart, lineEnd int) (string, errors.E) {
+	return PermalinkWithOptions(repoPath, filePath, lineStart, lineEnd, PermalinkOptions{
+		Ref: "",
+		Remote: InferGitRemoteOptions{
+			Remote:    "",
+			Push:      false,
+			Instances: nil,
+		},
+	})
+}
+
+// PermalinkWithOptions returns a web URL of the file at filePath in a git repository
+// at repoPath, as of the commit selected in options, highlighting lines from lineStart
+// to lineEnd (inclusive). The URL uses the commit hash so that it does not change
+// when the file changes later on.
+//
+// Relative filePath is resolved relative to repoPath, and then made relative to
+// the root of the repository's worktree. For bare repositories, filePath has to be
+// relative to the root of the repository.
+//
+// If lineStart is 0, no lines are highlighted. If lineEnd is 0 or equal to lineStart,
+// only lineStart is highlighted. The project and its git hosting service are inferred
+// using InferGitRemote. GitLab, GitHub, Gitea, and Bitbucket are supported.
+func PermalinkWithOptions(repoPath, filePath string, lineStart, lineEnd int, options PermalinkOptions) (string, errors.E) {
+	if lineStart < 0 || lineEnd < 0 || (lineEnd != 0 && lineEnd < lineStart) || (lineStart == 0 && lineEnd != 0) {
+		errE := errors.WithDetails(ErrInvalidPermalinkLine, "lineStart", lineStart)
+		errors.Details(errE)["lineEnd"] = lineEnd
+		return "", errE
+	}
+	if lineEnd == lineStart {
+		lineEnd = 0
+	}
+
+	remote, errE := InferGitRemote(repoPath, options.Remote)
+	if errE != nil {
 		return "", errE
 	}
 
-	url.Path = strings.TrimSuffix(url.Path, ".git")
-	url.Path = strings.TrimPrefix(url.Path, "/")
+	repository, errE := openGitRepository(repoPath)
+	if errE != nil {
+		return "", errE
+	}
+
+	ref := options.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	commit, errE := resolveGitCommit(repository, ref)
+	if errE != nil {
+		errors.Details(errE)["path"] = repoPath
+		return "", errE
+	}
 
-	return url.Path, nil
+	name, errE := gitRelativePath(repository, repoPath, filePath)
+	if errE != nil {
+		return "", errE
+	}
+
+	_, err := commit.File(name)
+	if err != nil {
+		errE := errors.WrapWith(err, ErrGitFileNotFound)
+		errors.Details(errE)["path"] = repoPath
+		errors.Details(errE)["file"] = name
+		errors.Details(errE)["commit"] = commit.Hash.String()
+		return "", errE
+	}
+
+	escaped := []string{}
+	for _, segment := range strings.Split(name, "/") {
+		escaped = append(escaped, url.PathEscape(segment))
+	}
+	filePart := strings.Join(escaped, "/")
+	sha := commit.Hash.String()
+	base := remote.ProjectURL()
+
+	start := strconv.Itoa(lineStart)
+	end := strconv.Itoa(lineEnd)
+
+	var link, fragment, rangeEnd string
+	switch remote.Forge { //nolint:exhaustive
+	case GitForgeGitLab:
+		link = base + "/-/blob/" + sha + "/" + filePart
+		fragment, rangeEnd = "#L"+start, "-"+end
+	case GitForgeGitHub:
+		link = base + "/blob/" + sha + "/" + filePart
+		fragment, rangeEnd = "#L"+start, "-L"+end
+	case GitForgeGitea:
+		link = base + "/src/commit/" + sha + "/" + filePart
+		fragment, rangeEnd = "#L"+start, "-L"+end
+	case GitForgeBitbucket:
+		link = base + "/src/" + sha + "/" + filePart
+		fragment, rangeEnd = "#lines-"+start, ":"+end
+	default:
+		errE = errors.WithDetails(ErrUnsupportedGitForge, "path", repoPath)
+		errors.Details(errE)["host"] = remote.Host
+		return "", errE
+	}
+
+	if lineStart == 0 {
+		return link, nil
+	}
+	if lineEnd == 0 {
+		return link + fragment, nil
+	}
+	return link + fragment + rangeEnd, nil
+}
+
+// resolveGitCommit resolves ref (a commit hash, a tag, a branch, or any other
+// revision supported by go-git) to a commit.
+func resolveGitCommit(repository *git.Repository, ref string) (*object.Commit, errors.E) {
+	hash, err := repository.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		errE := errors.WrapWith(err, ErrResolveGitRevision)
+		errors.Details(errE)["ref"] = ref
+		return nil, errE
+	}
+	commit, err := repository.CommitObject(*hash)
+	if err != nil {
+		errE := errors.WrapWith(err, ErrResolveGitRevision)
+		errors.Details(errE)["ref"] = ref
+		return nil, errE
+	}
+	return commit, nil
+}
+
+// gitRelativePath returns filePath relative to the root of the repository's worktree,
+// using forward slashes. Relative filePath is first resolved relative to repoPath.
+func gitRelativePath(repository *git.Repository, repoPath, filePath string) (string, errors.E) {
+	root := gitWorktreeRoot(repository)
+	if root == "" {
+		// Bare repository.
+		name := path.Clean(filepath.ToSlash(filePath))
+		if !fs.ValidPath(name) || name == "." {
+			errE := errors.WithDetails(ErrGitFileNotFound, "path", repoPath)
+			errors.Details(errE)["file"] = filePath
+			return "", errE
+		}
+		return name, nil
+	}
+
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(repoPath, filePath)
+	}
+	filePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", errors.WithDetails(err, "file", filePath)
+	}
+
+	// We resolve symlinks in directories (e.g., of temporary directories) so that
+	// both paths are comparable. The file itself might not exist in the worktree.
+	if r, err := filepath.EvalSymlinks(root); err == nil {
+		root = r
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(filePath)); err == nil {
+		filePath = filepath.Join(dir, filepath.Base(filePath))
+	}
+
+	rel, err := filepath.Rel(root, filePath)
+	if err != nil || !filepath.IsLocal(rel) {
+		errE := errors.WithDetails(ErrGitFileNotFound, "path", repoPath)
+		errors.Details(errE)["file"] = filePath
+		errors.Details(errE)["root"] = root
+		return "", errE
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+var ErrListGitCommits = errors.Base("cannot list git commits")
+
+// conventionalCommitRegexp matches the subject of a Conventional Commit,
+// e.g., "feat(parser)!: add arrays".
+//
+//nolint:gochecknoglobals
+var conventionalCommitRegexp = regexp.MustCompile(`^([A-Za-z]+)(?:\(([^()]*)\))?(!)?: +(\S.*)$`)
+
+// gitTrailerRegexp matches a git trailer line, e.g., "Signed-off-by: John Doe <john@doe.org>".
+//
+//nolint:gochecknoglobals
+var gitTrailerRegexp = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*|BREAKING CHANGE) *: *(.*)$`)
+
+// GitTrailer is a trailer of a commit message, e.g., "Signed-off-by: John Doe <john@doe.org>".
+type GitTrailer struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// GitCommit describes a git commit.
+type GitCommit struct {
+	// Commit is the full hash of the commit.
+	Commit string `json:"commit"`
+
+	// ShortCommit is the abbreviated hash of the commit.
+	ShortCommit string `json:"shortCommit"`
+
+	// Author is the name of the author.
+	Author string `json:"author"`
+
+	// AuthorEmail is the e-mail address of the author.
+	AuthorEmail string `json:"authorEmail"`
+
+	// Time is the author time of the commit.
+	Time time.Time `json:"time"`
+
+	// Subject is the first paragraph of the commit message, with lines joined with a space.
+	Subject string `json:"subject"`
+
+	// Body is the rest of the commit message, without trailers.
+	Body string `json:"body,omitempty"`
+
+	// Trailers are trailers from the last paragraph of the commit message.
+	// Continuation lines are joined with a space.
+	Trailers []GitTrailer `json:"trailers,omitempty"`
+
+	// Type is the lower-cased type of the Conventional Commit, e.g., "feat" or "fix".
+	// It is empty if the subject does not follow Conventional Commits.
+	Type string `json:"type,omitempty"`
+
+	// Scope is the scope of the Conventional Commit, if any.
+	Scope string `json:"scope,omitempty"`
+
+	// Description is the subject without the Conventional Commit prefix.
+	// It is equal to Subject if the subject does not follow Conventional Commits.
+	Description string `json:"description"`
+
+	// Breaking is true if the Conventional Commit is marked as a breaking change,
+	// using "!" or with a "BREAKING CHANGE" (or "BREAKING-CHANGE") trailer.
+	Breaking bool `json:"breaking,omitempty"`
+}
+
+// Trailer returns values of all trailers with key, compared case-insensitively.
+func (c *GitCommit) Trailer(key string) []string {
+	values := []string{}
+	for _, trailer := range c.Trailers {
+		if strings.EqualFold(trailer.Key, key) {
+			values = append(values, trailer.Value)
+		}
+	}
+	return values
+}
+
+// GitCommitsOptions are options for GitCommits.
+type GitCommitsOptions struct {
+	// From is the revision from which (exclusive) to list commits. If empty,
+	// the nearest tag reachable from To (but not To itself) is used, or all
+	// commits are listed if there is no such tag.
+	From string
+
+	// To is the revision up to which (inclusive) to list commits. If empty, "HEAD" is used.
+	To string
+
+	// Paths are path prefixes (relative to the root of the repository) to filter
+	// commits by. If set, only commits changing files under any of them are listed.
+	Paths []string
+}
+
+// parseGitCommitMessage parses the commit message into the subject, the body, and trailers.
+func parseGitCommitMessage(message string) (string, string, []GitTrailer) {
+	message = strings.ReplaceAll(message, "\r\n", "\n")
+	paragraphs := []string{}
+	for paragraph := range strings.SplitSeq(strings.TrimSpace(message), "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	if len(paragraphs) == 0 {
+		return "", "", nil
+	}
+
+	subject := strings.Join(strings.Fields(paragraphs[0]), " ")
+	paragraphs = paragraphs[1:]
+
+	var trailers []GitTrailer
+	if len(paragraphs) > 0 {
+		trailers = parseGitTrailers(paragraphs[len(paragraphs)-1])
+		if trailers != nil {
+			paragraphs = paragraphs[:len(paragraphs)-1]
+		}
+	}
+
+	return subject, strings.Join(paragraphs, "\n\n"), trailers
+}
+
+// parseGitTrailers parses paragraph as trailers. It returns nil if
+// the paragraph does not consist only of trailers.
+func parseGitTrailers(paragraph string) []GitTrailer {
+	trailers := []GitTrailer{}
+	for line := range strings.SplitSeq(paragraph, "\n") {
+		if len(trailers) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			// Continuation line.
+			trailers[len(trailers)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		match := gitTrailerRegexp.FindStringSubmatch(line)
+		if match == nil {
+			return nil
+		}
+		trailers = append(trailers, GitTrailer{
+			Key:   match[1],
+			Value: strings.TrimSpace(match[2]),
+		})
+	}
+	return trailers
+}
+
+// makeGitCommit creates GitCommit from commit.
+func makeGitCommit(commit *object.Commit) GitCommit {
+	subject, body, trailers := parseGitCommitMessage(commit.Message)
+	c := GitCommit{
+		Commit:      commit.Hash.String(),
+		ShortCommit: commit.Hash.String()[:gitShortHashLength],
+		Author:      commit.Author.Name,
+		AuthorEmail: commit.Author.Email,
+		Time:        commit.Author.When,
+		Subject:     subject,
+		Body:        body,
+		Trailers:    trailers,
+		Type:        "",
+		Scope:       "",
+		Description: subject,
+		Breaking:    false,
+	}
+	if match := conventionalCommitRegexp.FindStringSubmatch(subject); match != nil {
+		c.Type = strings.ToLower(match[1])
+		c.Scope = match[2]
+		c.Breaking = match[3] == "!"
+		c.Description = match[4]
+		for _, trailer := range trailers {
+			if trailer.Key == "BREAKING CHANGE" || trailer.Key == "BREAKING-CHANGE" {
+				c.Breaking = true
+			}
+		}
+	}
+	return c
+}
+
+// gitCommitChangesPaths returns true if commit changes any file under prefixes.
+// A merge commit changes a file only if it differs from all its parents.
+func gitCommitChangesPaths(commit *object.Commit, prefixes []string) (bool, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return false, err //nolint:wrapcheck
+	}
+
+	parents := []*object.Tree{}
+	err = commit.Parents().ForEach(func(parent *object.Commit) error {
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+		parents = append(parents, parentTree)
+		return nil
+	})
+	if err != nil {
+		return false, err //nolint:wrapcheck
+	}
+	if len(parents) == 0 {
+		// The root commit is compared with an empty tree.
+		parents = append(parents, nil)
+	}
+
+	for _, parent := range parents {
+		changes, err := object.DiffTree(parent, tree)
+		if err != nil {
+			return false, err //nolint:wrapcheck
+		}
+		changed := slices.ContainsFunc(changes, func(change *object.Change) bool {
+			return slices.ContainsFunc(prefixes, func(prefix string) bool {
+				return pathHasPrefix(change.From.Name, prefix) || pathHasPrefix(change.To.Name, prefix)
+			})
+		})
+		if !changed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// pathHasPrefix returns true if p is prefix or is under prefix.
+func pathHasPrefix(p, prefix string) bool {
+	return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// GitCommits lists commits of a git repository at path between two revisions,
+// like "git log From..To", newest first (by commit time).
+//
+// It uses go-git only and does not require the git binary.
+func GitCommits(path string, options GitCommitsOptions) ([]GitCommit, errors.E) {
+	repository, errE := openGitRepository(path)
+	if errE != nil {
+		return nil, errE
+	}
+
+	to := options.To
+	if to == "" {
+		to = "HEAD"
+	}
+	toCommit, errE := resolveGitCommit(repository, to)
+	if errE != nil {
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+
+	var fromCommit *object.Commit
+	if options.From != "" {
+		fromCommit, errE = resolveGitCommit(repository, options.From)
+		if errE != nil {
+			errors.Details(errE)["path"] = path
+			return nil, errE
+		}
+	} else {
+		tags, err := gitTags(repository)
+		if err != nil {
+			errE := errors.WrapWith(err, ErrListGitCommits)
+			errors.Details(errE)["path"] = path
+			return nil, errE
+		}
+		parents := []*object.Commit{}
+		err = toCommit.Parents().ForEach(func(parent *object.Commit) error {
+			parents = append(parents, parent)
+			return nil
+		})
+		if err == nil {
+			fromCommit, _, err = nearestGitTag(tags, parents)
+		}
+		if err != nil {
+			errE := errors.WrapWith(err, ErrListGitCommits)
+			errors.Details(errE)["path"] = path
+			return nil, errE
+		}
+	}
+
+	prefixes := []string{}
+	for _, p := range options.Paths {
+		prefixes = append(prefixes, strings.Trim(filepath.ToSlash(p), "/"))
+	}
+
+	var stop map[plumbing.Hash]bool
+	if fromCommit != nil {
+		var err error
+		stop, err = gitReachable(fromCommit, nil)
+		if err != nil {
+			errE := errors.WrapWith(err, ErrListGitCommits)
+			errors.Details(errE)["path"] = path
+			return nil, errE
+		}
+	}
+	reachable, err := gitReachable(toCommit, stop)
+	if err != nil {
+		errE := errors.WrapWith(err, ErrListGitCommits)
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+
+	commits := []*object.Commit{}
+	for hash := range reachable {
+		commit, err := repository.CommitObject(hash)
+		if err != nil {
+			errE := errors.WrapWith(err, ErrListGitCommits)
+			errors.Details(errE)["path"] = path
+			return nil, errE
+		}
+		if len(prefixes) > 0 {
+			changed, err := gitCommitChangesPaths(commit, prefixes)
+			if err != nil {
+				errE := errors.WrapWith(err, ErrListGitCommits)
+				errors.Details(errE)["path"] = path
+				errors.Details(errE)["commit"] = hash.String()
+				return nil, errE
+			}
+			if !changed {
+				continue
+			}
+		}
+		commits = append(commits, commit)
+	}
+
+	slices.SortFunc(commits, func(a, b *object.Commit) int {
+		if c := b.Committer.When.Compare(a.Committer.When); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Hash.String(), b.Hash.String())
+	})
+
+	result := make([]GitCommit, 0, len(commits))
+	for _, commit := range commits {
+		result = append(result, makeGitCommit(commit))
+	}
+	return result, nil
+}
+
+// GroupGitCommits groups commits by their Conventional Commit type.
+// Commits which do not follow Conventional Commits are grouped under
+// an empty type. The order of commits inside groups is preserved.
+func GroupGitCommits(commits []GitCommit) map[string][]GitCommit {
+	groups := map[string][]GitCommit{}
+	for _, commit := range commits {
+		groups[commit.Type] = append(groups[commit.Type], commit)
+	}
+	return groups
 }
@@ -0,0 +1,67 @@
+package x
+
+import (
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/tozd/go/errors"
+)
+
+func TestGitInfoFromBuildSettings(t *testing.T) {
+	t.Parallel()
+
+	info, errE := gitInfoFromBuildSettings([]debug.BuildSetting{
+		{Key: "-compiler", Value: "gc"},
+		{Key: "vcs", Value: "git"},
+		{Key: "vcs.revision", Value: "0123456789abcdef0123456789abcdef01234567"},
+		{Key: "vcs.time", Value: "2024-01-02T03:04:05+01:00"},
+		{Key: "vcs.modified", Value: "true"},
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, &GitRevision{
+		Commit:      "0123456789abcdef0123456789abcdef01234567",
+		ShortCommit: "0123456",
+		Branch:      "",
+		Tag:         "",
+		TagDistance: 0,
+		Dirty:       true,
+		Time:        time.Date(2024, time.January, 2, 2, 4, 5, 0, time.UTC),
+		Source:      GitRevisionSourceBuildInfo,
+	}, info)
+
+	info, errE = gitInfoFromBuildSettings([]debug.BuildSetting{
+		{Key: "vcs", Value: "git"},
+		{Key: "vcs.revision", Value: "01234"},
+		{Key: "vcs.modified", Value: "false"},
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "01234", info.Commit)
+	assert.Equal(t, "01234", info.ShortCommit)
+	assert.False(t, info.Dirty)
+	assert.True(t, info.Time.IsZero())
+
+	_, errE = gitInfoFromBuildSettings([]debug.BuildSetting{
+		{Key: "vcs", Value: "hg"},
+		{Key: "vcs.revision", Value: "0123456789abcdef0123456789abcdef01234567"},
+	})
+	assert.ErrorIs(t, errE, ErrNoBuildInfo)
+
+	_, errE = gitInfoFromBuildSettings([]debug.BuildSetting{
+		{Key: "vcs", Value: "git"},
+	})
+	assert.ErrorIs(t, errE, ErrNoBuildInfo)
+
+	_, errE = gitInfoFromBuildSettings(nil)
+	assert.ErrorIs(t, errE, ErrNoBuildInfo)
+
+	_, errE = gitInfoFromBuildSettings([]debug.BuildSetting{
+		{Key: "vcs", Value: "git"},
+		{Key: "vcs.revision", Value: "0123456789abcdef0123456789abcdef01234567"},
+		{Key: "vcs.time", Value: "yesterday"},
+	})
+	require.Error(t, errE)
+	assert.Equal(t, "yesterday", errors.Details(errE)["time"])
+}
@@ -0,0 +1,474 @@
+package x
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"gitlab.com/tozd/go/errors"
+)
+
+// maxGitSymlinks is the maximum number of symbolic links followed when
+// resolving a path, the same as Linux's limit.
+const maxGitSymlinks = 40
+
+// gitCommitFS is a read-only fs.FS over the tree of a git commit.
+type gitCommitFS struct {
+	storer  storer.EncodedObjectStorer
+	root    plumbing.Hash
+	modTime time.Time
+}
+
+var (
+	_ fs.FS         = (*gitCommitFS)(nil)
+	_ fs.ReadDirFS  = (*gitCommitFS)(nil)
+	_ fs.ReadFileFS = (*gitCommitFS)(nil)
+	_ fs.ReadLinkFS = (*gitCommitFS)(nil)
+	_ fs.StatFS     = (*gitCommitFS)(nil)
+)
+
+// GitCommitFS returns a read-only fs.FS over the tree of the commit rev
+// (a commit hash, a tag, a branch, or any other revision supported by go-git)
+// of a git repository at repoPath, without checking it out.
+//
+// Symbolic links are supported and are followed by Open, Stat, ReadFile, and ReadDir
+// as long as they point inside the tree. Submodules are exposed as empty directories.
+// Modification time of all files is the commit time of the commit.
+func GitCommitFS(repoPath, rev string) (fs.FS, errors.E) {
+	repository, errE := openGitRepository(repoPath)
+	if errE != nil {
+		return nil, errE
+	}
+
+	commit, errE := resolveGitCommit(repository, rev)
+	if errE != nil {
+		errors.Details(errE)["path"] = repoPath
+		return nil, errE
+	}
+
+	return &gitCommitFS{
+		storer:  repository.Storer,
+		root:    commit.TreeHash,
+		modTime: commit.Committer.When,
+	}, nil
+}
+
+// gitEntry is a resolved entry in the tree.
+type gitEntry struct {
+	name string
+	mode filemode.FileMode
+	hash plumbing.Hash
+}
+
+// lookup resolves name to an entry in the tree. Symbolic links are followed
+// for all path components but the last one, which is followed only if follow is true.
+func (f *gitCommitFS) lookup(op, name string, follow bool) (*gitEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, errors.WithStack(&fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid})
+	}
+
+	entry := &gitEntry{name: ".", mode: filemode.Dir, hash: f.root}
+	if name == "." {
+		return entry, nil
+	}
+
+	components := strings.Split(name, "/")
+	// Path of the entry resolved so far.
+	resolved := []string{}
+	links := 0
+	for len(components) > 0 {
+		component := components[0]
+		components = components[1:]
+
+		if entry.mode != filemode.Dir {
+			// Submodules are empty directories.
+			return nil, errors.WithStack(&fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist})
+		}
+
+		tree, err := object.GetTree(f.storer, entry.hash)
+		if err != nil {
+			return nil, errors.WithStack(&fs.PathError{Op: op, Path: name, Err: err})
+		}
+		i := slices.IndexFunc(tree.Entries, func(e object.TreeEntry) bool {
+			return e.Name == component
+		})
+		if i == -1 {
+			return nil, errors.WithStack(&fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist})
+		}
+
+		if tree.Entries[i].Mode == filemode.Symlink && (len(components) > 0 || follow) {
+			links++
+			if links > maxGitSymlinks {
+				return nil, errors.WithStack(&fs.PathError{Op: op, Path: name, Err: errors.New("too many links")})
+			}
+			target, err := f.readBlob(tree.Entries[i].Hash)
+			if err != nil {
+				return nil, errors.WithStack(&fs.PathError{Op: op, Path: name, Err: err})
+			}
+			if path.IsAbs(string(target)) {
+				return nil, errors.WithStack(&fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist})
+			}
+			p := path.Join(path.Join(resolved...), string(target))
+			if p == ".." || strings.HasPrefix(p, "../") {
+				// The link points outside of the tree.
+				return nil, errors.WithStack(&fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist})
+			}
+			// We restart the resolution from the root.
+			entry = &gitEntry{name: ".", mode: filemode.Dir, hash: f.root}
+			resolved = []string{}
+			if p != "." {
+				components = append(strings.Split(p, "/"), components...)
+			}
+			continue
+		}
+
+		entry = &gitEntry{name: component, mode: tree.Entries[i].Mode, hash: tree.Entries[i].Hash}
+		resolved = append(resolved, component)
+	}
+
+	// The name of the entry is the last component of name, even if it was a symbolic link.
+	entry.name = path.Base(name)
+	return entry, nil
+}
+
+// readBlob reads the whole contents of a blob.
+func (f *gitCommitFS) readBlob(hash plumbing.Hash) ([]byte, error) {
+	blob, err := object.GetBlob(f.storer, hash)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer reader.Close() //nolint:errcheck
+	data, err := io.ReadAll(reader)
+	return data, errors.WithStack(err)
+}
+
+// isGitDir returns true if mode is a directory or a submodule.
+func isGitDir(mode filemode.FileMode) bool {
+	return mode == filemode.Dir || mode == filemode.Submodule
+}
+
+// info returns fs.FileInfo for entry.
+func (f *gitCommitFS) info(entry *gitEntry) (fs.FileInfo, error) {
+	var size int64
+	if !isGitDir(entry.mode) {
+		obj, err := f.storer.EncodedObject(plumbing.BlobObject, entry.hash)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		size = obj.Size()
+	}
+
+	var mode fs.FileMode
+	switch entry.mode { //nolint:exhaustive
+	case filemode.Dir, filemode.Submodule:
+		mode = fs.ModeDir | 0o755 //nolint:mnd
+	case filemode.Executable:
+		mode = 0o755 //nolint:mnd
+	case filemode.Symlink:
+		mode = fs.ModeSymlink | 0o777 //nolint:mnd
+	default:
+		mode = 0o644 //nolint:mnd
+	}
+
+	return &gitFileInfo{
+		name:    entry.name,
+		size:    size,
+		mode:    mode,
+		modTime: f.modTime,
+	}, nil
+}
+
+// readDir returns sorted entries of a directory entry.
+func (f *gitCommitFS) readDir(entry *gitEntry) ([]fs.DirEntry, error) {
+	if entry.mode == filemode.Submodule {
+		return []fs.DirEntry{}, nil
+	}
+
+	tree, err := object.GetTree(f.storer, entry.hash)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	entries := make([]fs.DirEntry, 0, len(tree.Entries))
+	for _, e := range tree.Entries {
+		entries = append(entries, &gitDirEntry{
+			fs:    f,
+			entry: &gitEntry{name: e.Name, mode: e.Mode, hash: e.Hash},
+		})
+	}
+	// Git sorts directories as if their names end with "/", fs.ReadDir sorts by name.
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// Open implements fs.FS.
+func (f *gitCommitFS) Open(name string) (fs.File, error) {
+	entry, err := f.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.info(entry)
+	if err != nil {
+		return nil, errors.WithStack(&fs.PathError{Op: "open", Path: name, Err: err})
+	}
+
+	if isGitDir(entry.mode) {
+		entries, err := f.readDir(entry)
+		if err != nil {
+			return nil, errors.WithStack(&fs.PathError{Op: "open", Path: name, Err: err})
+		}
+		return &gitDir{
+			info:    info,
+			name:    name,
+			entries: entries,
+			offset:  0,
+		}, nil
+	}
+
+	data, err := f.readBlob(entry.hash)
+	if err != nil {
+		return nil, errors.WithStack(&fs.PathError{Op: "open", Path: name, Err: err})
+	}
+	return &gitFile{
+		Reader: bytes.NewReader(data),
+		info:   info,
+	}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (f *gitCommitFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := f.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !isGitDir(entry.mode) {
+		return nil, errors.WithStack(&fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")})
+	}
+
+	entries, err := f.readDir(entry)
+	if err != nil {
+		return nil, errors.WithStack(&fs.PathError{Op: "readdir", Path: name, Err: err})
+	}
+	return entries, nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (f *gitCommitFS) ReadFile(name string) ([]byte, error) {
+	entry, err := f.lookup("readfile", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if isGitDir(entry.mode) {
+		return nil, errors.WithStack(&fs.PathError{Op: "readfile", Path: name, Err: errors.New("is a directory")})
+	}
+
+	data, err := f.readBlob(entry.hash)
+	if err != nil {
+		return nil, errors.WithStack(&fs.PathError{Op: "readfile", Path: name, Err: err})
+	}
+	return data, nil
+}
+
+// ReadLink implements fs.ReadLinkFS.
+func (f *gitCommitFS) ReadLink(name string) (string, error) {
+	entry, err := f.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if entry.mode != filemode.Symlink {
+		return "", errors.WithStack(&fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid})
+	}
+
+	data, err := f.readBlob(entry.hash)
+	if err != nil {
+		return "", errors.WithStack(&fs.PathError{Op: "readlink", Path: name, Err: err})
+	}
+	return string(data), nil
+}
+
+// Lstat implements fs.ReadLinkFS.
+func (f *gitCommitFS) Lstat(name string) (fs.FileInfo, error) {
+	entry, err := f.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.info(entry)
+	if err != nil {
+		return nil, errors.WithStack(&fs.PathError{Op: "lstat", Path: name, Err: err})
+	}
+	return info, nil
+}
+
+// Stat implements fs.StatFS.
+func (f *gitCommitFS) Stat(name string) (fs.FileInfo, error) {
+	entry, err := f.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.info(entry)
+	if err != nil {
+		return nil, errors.WithStack(&fs.PathError{Op: "stat", Path: name, Err: err})
+	}
+	return info, nil
+}
+
+// gitFileInfo implements fs.FileInfo.
+type gitFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+var _ fs.FileInfo = (*gitFileInfo)(nil)
+
+// Name implements fs.FileInfo.
+func (i *gitFileInfo) Name() string {
+	return i.name
+}
+
+// Size implements fs.FileInfo.
+func (i *gitFileInfo) Size() int64 {
+	return i.size
+}
+
+// Mode implements fs.FileInfo.
+func (i *gitFileInfo) Mode() fs.FileMode {
+	return i.mode
+}
+
+// ModTime implements fs.FileInfo.
+func (i *gitFileInfo) ModTime() time.Time {
+	return i.modTime
+}
+
+// IsDir implements fs.FileInfo.
+func (i *gitFileInfo) IsDir() bool {
+	return i.mode.IsDir()
+}
+
+// Sys implements fs.FileInfo.
+func (i *gitFileInfo) Sys() any {
+	return nil
+}
+
+// gitDirEntry implements fs.DirEntry.
+type gitDirEntry struct {
+	fs    *gitCommitFS
+	entry *gitEntry
+}
+
+var _ fs.DirEntry = (*gitDirEntry)(nil)
+
+// Name implements fs.DirEntry.
+func (e *gitDirEntry) Name() string {
+	return e.entry.name
+}
+
+// IsDir implements fs.DirEntry.
+func (e *gitDirEntry) IsDir() bool {
+	return isGitDir(e.entry.mode)
+}
+
+// Type implements fs.DirEntry.
+func (e *gitDirEntry) Type() fs.FileMode {
+	switch e.entry.mode { //nolint:exhaustive
+	case filemode.Dir, filemode.Submodule:
+		return fs.ModeDir
+	case filemode.Symlink:
+		return fs.ModeSymlink
+	default:
+		return 0
+	}
+}
+
+// Info implements fs.DirEntry.
+func (e *gitDirEntry) Info() (fs.FileInfo, error) {
+	return e.fs.info(e.entry)
+}
+
+// String returns a description of the entry, like fs.FormatDirEntry.
+func (e *gitDirEntry) String() string {
+	return fs.FormatDirEntry(e)
+}
+
+// gitFile implements fs.File for regular files and symbolic links.
+type gitFile struct {
+	*bytes.Reader
+
+	info fs.FileInfo
+}
+
+var (
+	_ fs.File     = (*gitFile)(nil)
+	_ io.Seeker   = (*gitFile)(nil)
+	_ io.ReaderAt = (*gitFile)(nil)
+)
+
+// Stat implements fs.File.
+func (f *gitFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// Close implements fs.File.
+func (f *gitFile) Close() error {
+	return nil
+}
+
+// gitDir implements fs.ReadDirFile for directories.
+type gitDir struct {
+	info    fs.FileInfo
+	name    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+var _ fs.ReadDirFile = (*gitDir)(nil)
+
+// Stat implements fs.File.
+func (d *gitDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+// Read implements fs.File.
+func (d *gitDir) Read([]byte) (int, error) {
+	return 0, errors.WithStack(&fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")})
+}
+
+// Close implements fs.File.
+func (d *gitDir) Close() error {
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *gitDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		// return io.EOF without wrapping it.
+		return nil, io.EOF //nolint:wrapcheck
+	}
+	n = min(n, len(rest))
+	d.offset += n
+	return rest[:n], nil
+}
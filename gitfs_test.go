@@ -0,0 +1,215 @@
+package x_test
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/tozd/go/x"
+)
+
+func initGitCommitFSRepository(t *testing.T) (string, *git.Repository) {
+	t.Helper()
+
+	dir, repository := initGitRepository(t, nil)
+
+	for name, content := range map[string]string{
+		"config.yml":          "version: 1\n",
+		"assets/style.css":    "body {}\n",
+		"assets/img/logo.svg": "<svg/>\n",
+		"assets-list.txt":     "style.css\n",
+		"secret/key.txt":      "secret\n",
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o700))
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0o600))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0o700)) //nolint:gosec
+	require.NoError(t, os.Symlink("assets/style.css", filepath.Join(dir, "style.css")))
+	require.NoError(t, os.Symlink("../config.yml", filepath.Join(dir, "assets", "config.yml")))
+	require.NoError(t, os.Symlink("assets", filepath.Join(dir, "static")))
+	require.NoError(t, os.Symlink("../outside", filepath.Join(dir, "escape")))
+	require.NoError(t, os.Symlink("loop", filepath.Join(dir, "loop")))
+
+	workTree, err := repository.Worktree()
+	require.NoError(t, err)
+	err = workTree.AddWithOptions(&git.AddOptions{All: true}) //nolint:exhaustruct
+	require.NoError(t, err)
+	_, err = workTree.Commit("Initial commit.", &git.CommitOptions{ //nolint:exhaustruct
+		Author: &object.Signature{
+			Name:  "John Doe",
+			Email: "john@doe.org",
+			When:  time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+	})
+	require.NoError(t, err)
+
+	return dir, repository
+}
+
+func TestGitCommitFS(t *testing.T) {
+	t.Parallel()
+
+	dir, _ := initGitCommitFSRepository(t)
+
+	fsys, errE := x.GitCommitFS(dir, "HEAD")
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	// Broken symbolic links cannot be opened, so we exclude them for fstest.TestFS.
+	valid, errE := x.MakeFilteredFS(fsys, "escape", "loop")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	err := fstest.TestFS(valid,
+		"config.yml", "run.sh", "style.css", "assets-list.txt", "assets/style.css",
+		"assets/img/logo.svg", "assets/config.yml", "secret/key.txt",
+	)
+	require.NoError(t, err)
+
+	data, err := fs.ReadFile(fsys, "config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "version: 1\n", string(data))
+
+	// Symbolic links are followed.
+	data, err = fs.ReadFile(fsys, "style.css")
+	require.NoError(t, err)
+	assert.Equal(t, "body {}\n", string(data))
+	data, err = fs.ReadFile(fsys, "static/img/logo.svg")
+	require.NoError(t, err)
+	assert.Equal(t, "<svg/>\n", string(data))
+	data, err = fs.ReadFile(fsys, "assets/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, "version: 1\n", string(data))
+
+	target, err := fs.ReadLink(fsys, "style.css")
+	require.NoError(t, err)
+	assert.Equal(t, "assets/style.css", target)
+	_, err = fs.ReadLink(fsys, "config.yml")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+
+	info, err := fs.Lstat(fsys, "static")
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeSymlink, info.Mode().Type())
+	info, err = fs.Stat(fsys, "static")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.Equal(t, "static", info.Name())
+
+	info, err = fs.Stat(fsys, "run.sh")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o755), info.Mode())
+	assert.Equal(t, int64(len("#!/bin/sh\n")), info.Size())
+	assert.Equal(t, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), info.ModTime().UTC())
+
+	// Entries are sorted by name, not in git order.
+	entries, err := fs.ReadDir(fsys, ".")
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{
+		"assets", "assets-list.txt", "config.yml", "escape", "loop", "run.sh", "secret", "static", "style.css",
+	}, names)
+
+	_, err = fs.ReadFile(fsys, "escape")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fs.Stat(fsys, "loop")
+	require.Error(t, err)
+	_, err = fs.ReadFile(fsys, "missing.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fs.ReadFile(fsys, "config.yml/nested")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = fs.ReadFile(fsys, "../config.yml")
+	assert.ErrorIs(t, err, fs.ErrInvalid)
+
+	f, err := fsys.Open("assets/style.css")
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck
+	_, err = f.(io.Seeker).Seek(5, io.SeekStart)
+	require.NoError(t, err)
+	data, err = io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "{}\n", string(data))
+}
+
+func TestGitCommitFSRevision(t *testing.T) {
+	t.Parallel()
+
+	dir, repository := initGitRepository(t, nil)
+	first := commitGitFile(t, repository, dir, "config.yml", "version: 1\n")
+	_, err := repository.CreateTag("v1.0.0", first, &git.CreateTagOptions{
+		Tagger: &object.Signature{
+			Name:  "John Doe",
+			Email: "john@doe.org",
+			When:  time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Message: "Release v1.0.0.",
+		SignKey: nil,
+	})
+	require.NoError(t, err)
+	commitGitFile(t, repository, dir, "config.yml", "version: 2\n")
+
+	for rev, expected := range map[string]string{
+		"HEAD":         "version: 2\n",
+		"master":       "version: 2\n",
+		"v1.0.0":       "version: 1\n",
+		"HEAD~1":       "version: 1\n",
+		first.String(): "version: 1\n",
+	} {
+		fsys, errE := x.GitCommitFS(dir, rev)
+		require.NoError(t, errE, "% -+#.1v", errE)
+		data, err := fs.ReadFile(fsys, "config.yml")
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(data), rev)
+	}
+
+	_, errE := x.GitCommitFS(dir, "v9.9.9")
+	assert.ErrorIs(t, errE, x.ErrResolveGitRevision)
+
+	_, errE = x.GitCommitFS(t.TempDir(), "HEAD")
+	assert.ErrorIs(t, errE, x.ErrOpenGitRepository)
+
+	_, errE = x.GitCommitFS(dir, plumbing.ZeroHash.String())
+	assert.ErrorIs(t, errE, x.ErrResolveGitRevision)
+}
+
+func TestGitCommitFSFiltered(t *testing.T) {
+	t.Parallel()
+
+	dir, _ := initGitCommitFSRepository(t)
+
+	fsys, errE := x.GitCommitFS(dir, "HEAD")
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	filtered, errE := x.MakeFilteredFS(fsys, "secret", "assets/img", "escape", "loop")
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	err := fstest.TestFS(filtered, "config.yml", "assets/style.css")
+	require.NoError(t, err)
+
+	_, err = fs.ReadFile(filtered, "secret/key.txt")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	entries, err := fs.ReadDir(filtered, "assets")
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"config.yml", "style.css"}, names)
+
+	sub, err := fs.Sub(filtered, "assets")
+	require.NoError(t, err)
+	data, err := fs.ReadFile(sub, "style.css")
+	require.NoError(t, err)
+	assert.Equal(t, "body {}\n", string(data))
+}
@@ -5,8 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"math/big"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"gitlab.com/tozd/go/errors"
@@ -97,19 +101,105 @@ func DecodeJSONWithoutUnknownFields(reader io.Reader, v interface{}) errors.E {
 // Same as gitlab.com/tozd/go/zerolog.TimeFieldFormat.
 const timeFieldFormat = "2006-01-02T15:04:05.000Z07:00"
 
+const (
+	timeFieldFormatSeconds      = "2006-01-02T15:04:05Z07:00"
+	timeFieldFormatMicroseconds = "2006-01-02T15:04:05.000000Z07:00"
+	timeFieldFormatNanoseconds  = "2006-01-02T15:04:05.000000000Z07:00"
+)
+
+// timestampRegexp matches a decimal number with an optional exponent.
+//
+//nolint:gochecknoglobals
+var timestampRegexp = regexp.MustCompile(`^[+-]?[0-9]+(?:\.[0-9]*)?(?:[eE][+-]?[0-9]+)?$`)
+
+// parseTime parses s in RFC 3339 format (with any precision), or as a decimal number
+// (with an optional exponent) of units since the Unix epoch. A number with sub-nanosecond
+// precision is rounded to the nearest nanosecond.
+func parseTime(s string, unit time.Duration) (time.Time, errors.E) {
+	if !timestampRegexp.MatchString(s) {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, errors.WithDetails(err, "time", s)
+		}
+		return t, nil
+	}
+
+	rat, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return time.Time{}, errors.WithDetails(ErrInvalidTimestamp, "time", s)
+	}
+	rat.Mul(rat, big.NewRat(int64(unit), int64(time.Second)))
+	rat, _ = RoundRat(rat, 9, RoundHalfEven) //nolint:mnd
+	t, errE := TimeFromRat(rat)
+	if errE != nil {
+		errors.Details(errE)["time"] = s
+		return time.Time{}, errE
+	}
+	return t, nil
+}
+
+// unmarshalTimeJSON unmarshals a time from a JSON string or a JSON number,
+// as supported by parseTime.
+//
+// JSON null leaves t unchanged.
+func unmarshalTimeJSON(data []byte, unit time.Duration, t *time.Time) errors.E {
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		errE := UnmarshalWithoutUnknownFields(data, &s)
+		if errE != nil {
+			return errE
+		}
+	} else {
+		var n json.Number
+		errE := UnmarshalWithoutUnknownFields(data, &n)
+		if errE != nil {
+			return errE
+		}
+		if n == "" {
+			// JSON null.
+			return nil
+		}
+		s = n.String()
+	}
+
+	tt, errE := parseTime(s, unit)
+	if errE != nil {
+		return errE
+	}
+	*t = tt
+	return nil
+}
+
 // Time is the same as [time.Time], only that it marshals to JSON with millisecond
 // precision to minimize any side channels.
+//
+// It unmarshals from JSON strings in RFC 3339 format with any precision, and from
+// JSON numbers (or numeric strings) of seconds since the Unix epoch.
+//
+// See also TimeSeconds, TimeMicroseconds, TimeNanoseconds, UnixSeconds,
+// UnixMilliseconds, and UnixFloat, which unmarshal the same but marshal differently.
 type Time time.Time
 
 // MarshalJSON implements [json.Marshaler] interface for Time.
 func (t Time) MarshalJSON() ([]byte, error) {
-	return MarshalWithoutEscapeHTML(time.Time(t).Format(timeFieldFormat))
+	return MarshalWithoutEscapeHTML(t.String())
 }
 
-// UnmarshalJSON implements [json.Marshaler] interface for Time.
+// UnmarshalJSON implements [json.Unmarshaler] interface for Time.
 func (t *Time) UnmarshalJSON(data []byte) error {
-	var tt time.Time
-	errE := UnmarshalWithoutUnknownFields(data, &tt)
+	return unmarshalTimeJSON(data, time.Second, (*time.Time)(t))
+}
+
+// MarshalText implements [encoding.TextMarshaler] interface for Time.
+func (t Time) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler] interface for Time.
+//
+// It accepts the same values as UnmarshalJSON does inside JSON strings.
+func (t *Time) UnmarshalText(text []byte) error {
+	tt, errE := parseTime(string(text), time.Second)
 	if errE != nil {
 		return errE
 	}
@@ -117,30 +207,320 @@ func (t *Time) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// Duration is the same as [time.Duration], only that it marshals to JSON as string.
+// String implements [fmt.Stringer] interface for Time.
+//
+// It returns the same format as MarshalJSON, with millisecond precision.
+func (t Time) String() string {
+	return time.Time(t).Format(timeFieldFormat)
+}
+
+// GoString implements [fmt.GoStringer] interface for Time.
+func (t Time) GoString() string {
+	return "x.Time(" + time.Time(t).GoString() + ")"
+}
+
+// TimeSeconds is the same as Time, only that it marshals to JSON with second precision.
+type TimeSeconds time.Time
+
+// MarshalJSON implements [json.Marshaler] interface for TimeSeconds.
+func (t TimeSeconds) MarshalJSON() ([]byte, error) {
+	return MarshalWithoutEscapeHTML(time.Time(t).Format(timeFieldFormatSeconds))
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for TimeSeconds.
+func (t *TimeSeconds) UnmarshalJSON(data []byte) error {
+	return unmarshalTimeJSON(data, time.Second, (*time.Time)(t))
+}
+
+// TimeMicroseconds is the same as Time, only that it marshals to JSON with microsecond precision.
+type TimeMicroseconds time.Time
+
+// MarshalJSON implements [json.Marshaler] interface for TimeMicroseconds.
+func (t TimeMicroseconds) MarshalJSON() ([]byte, error) {
+	return MarshalWithoutEscapeHTML(time.Time(t).Format(timeFieldFormatMicroseconds))
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for TimeMicroseconds.
+func (t *TimeMicroseconds) UnmarshalJSON(data []byte) error {
+	return unmarshalTimeJSON(data, time.Second, (*time.Time)(t))
+}
+
+// TimeNanoseconds is the same as Time, only that it marshals to JSON with nanosecond precision.
+type TimeNanoseconds time.Time
+
+// MarshalJSON implements [json.Marshaler] interface for TimeNanoseconds.
+func (t TimeNanoseconds) MarshalJSON() ([]byte, error) {
+	return MarshalWithoutEscapeHTML(time.Time(t).Format(timeFieldFormatNanoseconds))
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for TimeNanoseconds.
+func (t *TimeNanoseconds) UnmarshalJSON(data []byte) error {
+	return unmarshalTimeJSON(data, time.Second, (*time.Time)(t))
+}
+
+// UnixSeconds is the same as [time.Time], only that it marshals to JSON as a number
+// of whole seconds since the Unix epoch, rounded down.
+//
+// It unmarshals the same as Time.
+type UnixSeconds time.Time
+
+// MarshalJSON implements [json.Marshaler] interface for UnixSeconds.
+func (t UnixSeconds) MarshalJSON() ([]byte, error) {
+	return MarshalWithoutEscapeHTML(time.Time(t).Unix())
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for UnixSeconds.
+func (t *UnixSeconds) UnmarshalJSON(data []byte) error {
+	return unmarshalTimeJSON(data, time.Second, (*time.Time)(t))
+}
+
+// UnixMilliseconds is the same as [time.Time], only that it marshals to JSON as a number
+// of whole milliseconds since the Unix epoch, rounded down.
+//
+// It unmarshals the same as Time, only that JSON numbers (and numeric strings)
+// are milliseconds since the Unix epoch.
+type UnixMilliseconds time.Time
+
+// MarshalJSON implements [json.Marshaler] interface for UnixMilliseconds.
+func (t UnixMilliseconds) MarshalJSON() ([]byte, error) {
+	return MarshalWithoutEscapeHTML(time.Time(t).UnixMilli())
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for UnixMilliseconds.
+func (t *UnixMilliseconds) UnmarshalJSON(data []byte) error {
+	return unmarshalTimeJSON(data, time.Millisecond, (*time.Time)(t))
+}
+
+// UnixFloat is the same as [time.Time], only that it marshals to JSON as a number
+// of seconds since the Unix epoch with a fractional part (see TimeToFloat64).
+//
+// It unmarshals the same as Time.
+type UnixFloat time.Time
+
+// MarshalJSON implements [json.Marshaler] interface for UnixFloat.
+func (t UnixFloat) MarshalJSON() ([]byte, error) {
+	return MarshalWithoutEscapeHTML(TimeToFloat64(time.Time(t)))
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for UnixFloat.
+func (t *UnixFloat) UnmarshalJSON(data []byte) error {
+	return unmarshalTimeJSON(data, time.Second, (*time.Time)(t))
+}
+
+// unmarshalDurationJSON unmarshals a duration from a JSON string in any form
+// supported by ParseDurationWithOptions, or from a JSON number of seconds.
+//
+// JSON null leaves d unchanged.
+func unmarshalDurationJSON(data []byte, options DurationOptions, d *time.Duration) errors.E {
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		errE := UnmarshalWithoutUnknownFields(data, &s)
+		if errE != nil {
+			return errE
+		}
+	} else {
+		var n json.Number
+		errE := UnmarshalWithoutUnknownFields(data, &n)
+		if errE != nil {
+			return errE
+		}
+		if n == "" {
+			// JSON null.
+			return nil
+		}
+		s = n.String()
+	}
+
+	tmp, errE := ParseDurationWithOptions(s, options)
+	if errE != nil {
+		return errE
+	}
+	*d = tmp
+	return nil
+}
+
+// Duration is the same as [time.Duration], only that it marshals to JSON as string
+// in Go syntax (e.g., "1h30m0s").
+//
+// It unmarshals from JSON strings in Go syntax (extended with "d" and "w" units),
+// ISO 8601 durations, and numbers of seconds, and from JSON numbers of seconds.
+// See ParseDuration for details.
 type Duration time.Duration
 
 // MarshalJSON implements [json.Marshaler] interface for Duration.
 func (d Duration) MarshalJSON() ([]byte, error) {
-	return MarshalWithoutEscapeHTML(time.Duration(d).String())
+	return MarshalWithoutEscapeHTML(d.String())
 }
 
-// UnmarshalJSON implements [json.Marshaler] interface for Duration.
-func (d *Duration) UnmarshalJSON(b []byte) error {
-	var s string
-	errE := UnmarshalWithoutUnknownFields(b, &s)
+// UnmarshalJSON implements [json.Unmarshaler] interface for Duration.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	return unmarshalDurationJSON(data, DurationOptions{Strict: false}, (*time.Duration)(d))
+}
+
+// MarshalText implements [encoding.TextMarshaler] interface for Duration.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler] interface for Duration.
+//
+// It accepts the same values as ParseDuration.
+func (d *Duration) UnmarshalText(text []byte) error {
+	tmp, errE := ParseDuration(string(text))
 	if errE != nil {
 		return errE
 	}
-	tmp, err := time.ParseDuration(s)
-	if err != nil {
-		return errors.WithDetails(err, "duration", s)
-	}
 	*d = Duration(tmp)
 	return nil
 }
 
+// String implements [fmt.Stringer] interface for Duration.
+func (d Duration) String() string {
+	return FormatDuration(time.Duration(d), DurationFormatGo)
+}
+
+// GoString implements [fmt.GoStringer] interface for Duration.
+func (d Duration) GoString() string {
+	return "x.Duration(" + strconv.FormatInt(int64(d), 10) + ")" //nolint:mnd
+}
+
+// DurationISO8601 is the same as Duration, only that it marshals to JSON as string
+// in ISO 8601 format (e.g., "PT1H30M").
+type DurationISO8601 time.Duration
+
+// MarshalJSON implements [json.Marshaler] interface for DurationISO8601.
+func (d DurationISO8601) MarshalJSON() ([]byte, error) {
+	return MarshalWithoutEscapeHTML(FormatDuration(time.Duration(d), DurationFormatISO8601))
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for DurationISO8601.
+func (d *DurationISO8601) UnmarshalJSON(data []byte) error {
+	return unmarshalDurationJSON(data, DurationOptions{Strict: false}, (*time.Duration)(d))
+}
+
+// StrictDuration is the same as Duration, only that it unmarshals in strict mode,
+// rejecting years and months in ISO 8601 durations.
+type StrictDuration time.Duration
+
+// MarshalJSON implements [json.Marshaler] interface for StrictDuration.
+func (d StrictDuration) MarshalJSON() ([]byte, error) {
+	return MarshalWithoutEscapeHTML(FormatDuration(time.Duration(d), DurationFormatGo))
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for StrictDuration.
+func (d *StrictDuration) UnmarshalJSON(data []byte) error {
+	return unmarshalDurationJSON(data, DurationOptions{Strict: true}, (*time.Duration)(d))
+}
+
+// StrictDurationISO8601 is the same as DurationISO8601, only that it unmarshals in strict mode,
+// rejecting years and months in ISO 8601 durations.
+type StrictDurationISO8601 time.Duration
+
+// MarshalJSON implements [json.Marshaler] interface for StrictDurationISO8601.
+func (d StrictDurationISO8601) MarshalJSON() ([]byte, error) {
+	return MarshalWithoutEscapeHTML(FormatDuration(time.Duration(d), DurationFormatISO8601))
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for StrictDurationISO8601.
+func (d *StrictDurationISO8601) UnmarshalJSON(data []byte) error {
+	return unmarshalDurationJSON(data, DurationOptions{Strict: true}, (*time.Duration)(d))
+}
+
+// Rat is the same as [big.Rat], only that it marshals to JSON as an exact
+// decimal string (e.g., "12.34") when the number has no repeating digits,
+// and as a "num/den" string (e.g., "1/3") otherwise.
+//
+// It never marshals to the repeating notation (e.g., "0.1(6)"): that would require
+// computing the number of repeating digits with RatPrecision, which can take very long
+// and produce very long strings for large denominators (see FormatRat). Instead,
+// Rat.FloatPrec is used, which only determines if there are any repeating digits.
+//
+// It unmarshals from JSON numbers (without going through float64), and from strings
+// in those two forms or in the repeating notation (e.g., "0.1(6)", see ParseRat).
+//
+// MarshalJSON has a value receiver (like Time and Duration) so that Rat values which are
+// not addressable (e.g., in a struct passed by value or in a map) are marshaled as well.
+type Rat big.Rat
+
+// MarshalJSON implements [json.Marshaler] interface for Rat.
+func (r Rat) MarshalJSON() ([]byte, error) {
+	// r is a shallow copy which shares internal state with the original,
+	// so we copy it into a fresh big.Rat before using it.
+	rat := new(big.Rat).Set((*big.Rat)(&r))
+	n, exact := rat.FloatPrec()
+	if exact {
+		return MarshalWithoutEscapeHTML(rat.FloatString(n))
+	}
+	return MarshalWithoutEscapeHTML(rat.String())
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for Rat.
+func (r *Rat) UnmarshalJSON(data []byte) error {
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		errE := UnmarshalWithoutUnknownFields(data, &s)
+		if errE != nil {
+			return errE
+		}
+	} else {
+		var n json.Number
+		errE := UnmarshalWithoutUnknownFields(data, &n)
+		if errE != nil {
+			return errE
+		}
+		if n == "" {
+			// JSON null.
+			return nil
+		}
+		s = n.String()
+	}
+
+	rat, errE := parseRatJSON(s)
+	if errE != nil {
+		return errE
+	}
+	(*big.Rat)(r).Set(rat)
+	return nil
+}
+
+// ratExponentRegexp matches a decimal number with an exponent, as allowed in JSON numbers.
+//
+//nolint:gochecknoglobals
+var ratExponentRegexp = regexp.MustCompile(`^[+-]?[0-9]+(?:\.[0-9]*)?[eE][+-]?[0-9]+$`)
+
+// parseRatJSON parses s as a "num/den" fraction, as a decimal number with
+// an optional exponent, or in the repeating notation.
+func parseRatJSON(s string) (*big.Rat, errors.E) {
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		// We do not use Rat.SetString because it supports base prefixes.
+		n, ok := new(big.Int).SetString(num, 10) //nolint:mnd
+		if !ok {
+			return nil, errors.WithDetails(ErrInvalidRat, "value", s)
+		}
+		d, ok := new(big.Int).SetString(den, 10) //nolint:mnd
+		if !ok || d.Sign() <= 0 || strings.HasPrefix(den, "+") {
+			return nil, errors.WithDetails(ErrInvalidRat, "value", s)
+		}
+		return new(big.Rat).SetFrac(n, d), nil
+	}
+
+	if ratExponentRegexp.MatchString(s) {
+		rat, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, errors.WithDetails(ErrInvalidRat, "value", s)
+		}
+		return rat, nil
+	}
+
+	return ParseRat(s)
+}
+
 // SaveJSONToDir saves each element of the slice into individual files with JSON representation to a directory.
+//
+// filename is called for each element to obtain the filename (without the .json extension).
+// If the filename is derived from IDs or other untrusted input, consider using EncodeFilename,
+// which produces safe filenames without collisions (also on case-insensitive filesystems),
+// but which can be too long for long inputs, or SafeFilename.
 func SaveJSONToDir[T any](ctx context.Context, dir string, data []T, filename func(T) (string, errors.E)) errors.E {
 	if len(data) == 0 {
 		return nil
@@ -3,6 +3,9 @@ package x_test
 import (
 	"context"
 	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
 	"strings"
@@ -145,6 +148,105 @@ func TestTime(t *testing.T) {
 	})
 }
 
+func TestTimeVariants(t *testing.T) {
+	t.Parallel()
+
+	value := time.Date(2023, time.November, 14, 22, 13, 20, 123456789, time.UTC)
+
+	for _, tt := range []struct {
+		value    any
+		expected string
+	}{
+		{x.Time(value), `"2023-11-14T22:13:20.123Z"`},
+		{x.TimeSeconds(value), `"2023-11-14T22:13:20Z"`},
+		{x.TimeMicroseconds(value), `"2023-11-14T22:13:20.123456Z"`},
+		{x.TimeNanoseconds(value), `"2023-11-14T22:13:20.123456789Z"`},
+		{x.TimeNanoseconds(value.In(time.FixedZone("", 2*60*60))), `"2023-11-15T00:13:20.123456789+02:00"`},
+		{x.UnixSeconds(value), `1700000000`},
+		{x.UnixSeconds(time.Date(1969, time.December, 31, 23, 59, 59, 500000000, time.UTC)), `-1`},
+		{x.UnixMilliseconds(value), `1700000000123`},
+		{x.UnixFloat(value), `1700000000.1234567`},
+	} {
+		data, err := json.Marshal(tt.value)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, string(data))
+	}
+}
+
+func TestTimeUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	value := time.Date(2023, time.November, 14, 22, 13, 20, 123456789, time.UTC)
+
+	for _, tt := range []struct {
+		data     string
+		expected time.Time
+	}{
+		{`"2023-11-14T22:13:20Z"`, value.Truncate(time.Second)},
+		{`"2023-11-14T22:13:20.123Z"`, value.Truncate(time.Millisecond)},
+		{`"2023-11-14T22:13:20.123456789Z"`, value},
+		{`"2023-11-15T00:13:20.123456789+02:00"`, value},
+		{`1700000000`, value.Truncate(time.Second)},
+		{`1700000000.123456789`, value},
+		{`"1700000000.123456789"`, value},
+		{`1.700000000123456789e9`, value},
+		{`1700000000.1234567891`, value},
+		{`1700000000.1234567`, time.Date(2023, time.November, 14, 22, 13, 20, 123456700, time.UTC)},
+		{`-0.5`, time.Date(1969, time.December, 31, 23, 59, 59, 500000000, time.UTC)},
+	} {
+		var xt x.Time
+		err := json.Unmarshal([]byte(tt.data), &xt)
+		require.NoError(t, err, "% -+#.1v", err)
+		assert.True(t, tt.expected.Equal(time.Time(xt)), "%s: got %v", tt.data, time.Time(xt).UTC())
+
+		var xts x.TimeSeconds
+		err = json.Unmarshal([]byte(tt.data), &xts)
+		require.NoError(t, err, "% -+#.1v", err)
+		assert.True(t, tt.expected.Equal(time.Time(xts)), "%s: got %v", tt.data, time.Time(xts).UTC())
+
+		var xtu x.UnixSeconds
+		err = json.Unmarshal([]byte(tt.data), &xtu)
+		require.NoError(t, err, "% -+#.1v", err)
+		assert.True(t, tt.expected.Equal(time.Time(xtu)), "%s: got %v", tt.data, time.Time(xtu).UTC())
+
+		var xtf x.UnixFloat
+		err = json.Unmarshal([]byte(tt.data), &xtf)
+		require.NoError(t, err, "% -+#.1v", err)
+		assert.True(t, tt.expected.Equal(time.Time(xtf)), "%s: got %v", tt.data, time.Time(xtf).UTC())
+	}
+
+	// Numbers are milliseconds for UnixMilliseconds.
+	for _, data := range []string{`1700000000123.456789`, `"1700000000123.456789"`, `"2023-11-14T22:13:20.123456789Z"`} {
+		var xtm x.UnixMilliseconds
+		err := json.Unmarshal([]byte(data), &xtm)
+		require.NoError(t, err, "% -+#.1v", err)
+		assert.True(t, value.Equal(time.Time(xtm)), "%s: got %v", data, time.Time(xtm).UTC())
+	}
+
+	// Round trips.
+	for _, v := range []any{
+		new(x.Time), new(x.TimeSeconds), new(x.TimeMicroseconds), new(x.TimeNanoseconds),
+		new(x.UnixSeconds), new(x.UnixMilliseconds), new(x.UnixFloat),
+	} {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		err = json.Unmarshal(data, v)
+		require.NoError(t, err, "% -+#.1v", err)
+	}
+
+	// JSON null is a no-op.
+	xt := x.Time(value)
+	err := json.Unmarshal([]byte(`null`), &xt)
+	require.NoError(t, err)
+	assert.Equal(t, value, time.Time(xt))
+
+	for _, data := range []string{`"not-a-time"`, `true`, `{}`, `"1/2"`, `"0x10"`, `""`, `"1e99"`} {
+		var xt x.Time
+		err := json.Unmarshal([]byte(data), &xt)
+		assert.Error(t, err, data)
+	}
+}
+
 func TestDuration(t *testing.T) {
 	t.Parallel()
 
@@ -167,15 +269,252 @@ func TestDuration(t *testing.T) {
 		assert.Error(t, err)
 	})
 
-	t.Run("non-string duration", func(t *testing.T) {
+	t.Run("number duration", func(t *testing.T) {
 		t.Parallel()
 
 		var d4 x.Duration
 		err := json.Unmarshal([]byte(`123`), &d4)
+		require.NoError(t, err, "% -+#.1v", err)
+		assert.Equal(t, x.Duration(123*time.Second), d4)
+	})
+
+	t.Run("non-string duration", func(t *testing.T) {
+		t.Parallel()
+
+		var d5 x.Duration
+		err := json.Unmarshal([]byte(`true`), &d5)
 		assert.Error(t, err)
 	})
 }
 
+func TestTimeText(t *testing.T) {
+	t.Parallel()
+
+	value := time.Date(2023, time.November, 14, 22, 13, 20, 123456789, time.UTC)
+	xt := x.Time(value)
+
+	text, err := xt.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "2023-11-14T22:13:20.123Z", string(text))
+	assert.Equal(t, "2023-11-14T22:13:20.123Z", fmt.Sprint(xt))
+	assert.Equal(t, "x.Time(time.Date(2023, time.November, 14, 22, 13, 20, 123456789, time.UTC))", fmt.Sprintf("%#v", xt))
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var flagTime x.Time
+	fs.TextVar(&flagTime, "time", x.Time{}, "")
+	err = fs.Parse([]string{"-time", "2023-11-14T22:13:20.123456789Z"})
+	require.NoError(t, err)
+	assert.True(t, value.Equal(time.Time(flagTime)))
+
+	err = flagTime.UnmarshalText([]byte("1700000000.5"))
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.True(t, time.Unix(1700000000, 500000000).Equal(time.Time(flagTime)))
+
+	err = flagTime.UnmarshalText([]byte("invalid"))
+	assert.Error(t, err)
+	err = flagTime.UnmarshalText([]byte(""))
+	assert.Error(t, err)
+}
+
+func TestDurationText(t *testing.T) {
+	t.Parallel()
+
+	d := x.Duration(90 * time.Minute)
+
+	text, err := d.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "1h30m0s", string(text))
+	assert.Equal(t, "1h30m0s", fmt.Sprint(d))
+	assert.Equal(t, "x.Duration(5400000000000)", fmt.Sprintf("%#v", d))
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var flagDuration x.Duration
+	fs.TextVar(&flagDuration, "duration", x.Duration(time.Second), "")
+	err = fs.Parse([]string{"-duration", "PT1H30M"})
+	require.NoError(t, err)
+	assert.Equal(t, d, flagDuration)
+
+	err = flagDuration.UnmarshalText([]byte("1d"))
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, x.Duration(24*time.Hour), flagDuration)
+
+	err = flagDuration.UnmarshalText([]byte("invalid"))
+	assert.Error(t, err)
+}
+
+func TestDurationVariants(t *testing.T) {
+	t.Parallel()
+
+	d := 90*time.Minute + 500*time.Millisecond
+
+	for _, tt := range []struct {
+		value    any
+		expected string
+	}{
+		{x.Duration(d), `"1h30m0.5s"`},
+		{x.StrictDuration(d), `"1h30m0.5s"`},
+		{x.DurationISO8601(d), `"PT1H30M0.5S"`},
+		{x.StrictDurationISO8601(d), `"PT1H30M0.5S"`},
+	} {
+		data, err := json.Marshal(tt.value)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, string(data))
+	}
+
+	for _, data := range []string{`"1h30m0.5s"`, `"PT1H30M0.5S"`, `"5400.5"`, `5400.5`, `"0.0625d0.5s"`} {
+		var d1 x.Duration
+		err := json.Unmarshal([]byte(data), &d1)
+		require.NoError(t, err, "% -+#.1v", err)
+		assert.Equal(t, x.Duration(d), d1, data)
+
+		var d2 x.StrictDurationISO8601
+		err = json.Unmarshal([]byte(data), &d2)
+		require.NoError(t, err, "% -+#.1v", err)
+		assert.Equal(t, x.StrictDurationISO8601(d), d2, data)
+	}
+
+	var d3 x.DurationISO8601
+	err := json.Unmarshal([]byte(`"P1M"`), &d3)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, x.DurationISO8601(30*24*time.Hour), d3)
+
+	var d4 x.StrictDuration
+	err = json.Unmarshal([]byte(`"P1M"`), &d4)
+	assert.ErrorIs(t, err, x.ErrAmbiguousDuration)
+
+	// JSON null is a no-op.
+	d5 := x.Duration(d)
+	err = json.Unmarshal([]byte(`null`), &d5)
+	require.NoError(t, err)
+	assert.Equal(t, x.Duration(d), d5)
+}
+
+func TestRat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rat      string
+		expected string
+	}{
+		{"0", `"0"`},
+		{"12.34", `"12.34"`},
+		{"-12.34", `"-12.34"`},
+		{"100", `"100"`},
+		{"1/1024", `"0.0009765625"`},
+		{"1/3", `"1/3"`},
+		{"-10/6", `"-5/3"`},
+		{"12345678901234567890.123456789012345678901", `"12345678901234567890.123456789012345678901"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rat, func(t *testing.T) {
+			t.Parallel()
+
+			r, ok := new(big.Rat).SetString(tt.rat)
+			require.True(t, ok)
+
+			data, err := json.Marshal((*x.Rat)(r))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(data))
+
+			data, err = json.Marshal(x.Rat(*r))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(data))
+
+			var r2 x.Rat
+			err = json.Unmarshal(data, &r2)
+			require.NoError(t, err)
+			assert.Equal(t, r.String(), (*big.Rat)(&r2).String())
+		})
+	}
+
+	t.Run("struct", func(t *testing.T) {
+		t.Parallel()
+
+		type invoice struct {
+			Amount x.Rat  `json:"amount"`
+			Tax    *x.Rat `json:"tax"`
+		}
+
+		i := &invoice{Tax: (*x.Rat)(big.NewRat(1, 3))} //nolint:exhaustruct
+		(*big.Rat)(&i.Amount).SetFrac64(1999, 100)
+		data, err := json.Marshal(i)
+		require.NoError(t, err)
+		assert.Equal(t, `{"amount":"19.99","tax":"1/3"}`, string(data))
+
+		// Struct passed by value, so the Rat field is not addressable.
+		data, err = json.Marshal(*i)
+		require.NoError(t, err)
+		assert.Equal(t, `{"amount":"19.99","tax":"1/3"}`, string(data))
+
+		data, err = json.Marshal(struct {
+			Invoices []invoice `json:"invoices"`
+		}{Invoices: []invoice{*i}})
+		require.NoError(t, err)
+		assert.Equal(t, `{"invoices":[{"amount":"19.99","tax":"1/3"}]}`, string(data))
+
+		// Map values are not addressable either.
+		data, err = json.Marshal(map[string]x.Rat{"a": i.Amount, "b": *i.Tax})
+		require.NoError(t, err)
+		assert.Equal(t, `{"a":"19.99","b":"1/3"}`, string(data))
+
+		// The original is not modified by marshaling.
+		assert.Equal(t, "1999/100", (*big.Rat)(&i.Amount).String())
+
+		var i2 invoice
+		err = json.Unmarshal([]byte(`{"amount":0.1,"tax":null}`), &i2)
+		require.NoError(t, err)
+		assert.Equal(t, "1/10", (*big.Rat)(&i2.Amount).String())
+		assert.Nil(t, i2.Tax)
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		t.Parallel()
+
+		for data, expected := range map[string]string{
+			`0.1`:                      "1/10",
+			`-0.1`:                     "-1/10",
+			`123456789012345678901234`: "123456789012345678901234/1",
+			`1.5e3`:                    "1500/1",
+			`1E-2`:                     "1/100",
+			`"0.1"`:                    "1/10",
+			`"0.1(6)"`:                 "1/6",
+			`"-1/3"`:                   "-1/3",
+			`"2/4"`:                    "1/2",
+			`"010/3"`:                  "10/3",
+			`"2.5e-1"`:                 "1/4",
+		} {
+			var r x.Rat
+			err := json.Unmarshal([]byte(data), &r)
+			require.NoError(t, err, data)
+			assert.Equal(t, expected, (*big.Rat)(&r).String(), data)
+		}
+
+		// JSON null does not change the value.
+		r := x.Rat(*big.NewRat(1, 2))
+		err := json.Unmarshal([]byte(`null`), &r)
+		require.NoError(t, err)
+		assert.Equal(t, "1/2", (*big.Rat)(&r).String())
+	})
+
+	t.Run("unmarshal error", func(t *testing.T) {
+		t.Parallel()
+
+		for _, data := range []string{
+			`"abc"`, `""`, `"1/0"`, `"1/-3"`, `"1/+3"`, `"0x10"`, `"0x1/3"`, `"1_000"`, `" 1"`, `"Inf"`,
+			`"1e1000000000"`, `true`, `{}`, `[]`,
+		} {
+			var r x.Rat
+			err := json.Unmarshal([]byte(data), &r)
+			assert.Error(t, err, data)
+		}
+
+		var r x.Rat
+		err := json.Unmarshal([]byte(`"1/0"`), &r)
+		assert.ErrorIs(t, err, x.ErrInvalidRat)
+	})
+}
+
 func TestSaveJSONToDir(t *testing.T) {
 	t.Parallel()
 
@@ -1,49 +1,771 @@
 package x
 
 import (
-	"regexp"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"gitlab.com/tozd/go/errors"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
 )
 
-var (
-	invalidChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1F]`)
+// FilenameProfile selects the rules of a target platform (filesystem)
+// which a filename has to satisfy.
+type FilenameProfile int
+
+const (
+	// FilenameProfileWindows are rules of Windows (NTFS).
+	FilenameProfileWindows FilenameProfile = iota
+	// FilenameProfileMacOS are rules of macOS (APFS).
+	FilenameProfileMacOS
+	// FilenameProfileLinux are rules of Linux (ext4).
+	FilenameProfileLinux
+	// FilenameProfilePOSIX are rules of the POSIX portable filename character set.
+	FilenameProfilePOSIX
+	// FilenameProfileFAT32 are rules of FAT32 (with long filenames).
+	FilenameProfileFAT32
+)
 
+// String returns the name of the profile.
+func (p FilenameProfile) String() string {
+	switch p {
+	case FilenameProfileWindows:
+		return "windows"
+	case FilenameProfileMacOS:
+		return "macos"
+	case FilenameProfileLinux:
+		return "linux"
+	case FilenameProfilePOSIX:
+		return "posix"
+	case FilenameProfileFAT32:
+		return "fat32"
+	}
+	return "unknown"
+}
+
+// filenameRules describes what a filename has to satisfy for a profile.
+type filenameRules struct {
+	// invalidChar reports if the rune is not allowed in a filename.
+	invalidChar func(r rune) bool
+	// validUTF8 is true if the filename has to be valid UTF-8 (or UTF-16).
+	validUTF8 bool
+	// reservedNames are reserved (upper case) names, without the extension.
+	reservedNames map[string]bool
+	// noTrailingDotSpace is true if the filename cannot end with a dot or a space.
+	noTrailingDotSpace bool
+	// noLeadingDash is true if the filename cannot start with a dash.
+	noLeadingDash bool
+	// caseInsensitive is true if the filesystem compares filenames case-insensitively.
+	caseInsensitive bool
+	// normalizationInsensitive is true if the filesystem compares filenames
+	// after Unicode normalization.
+	normalizationInsensitive bool
+	// maxLength is the maximum length of the filename.
+	maxLength int
+	// lengthUTF16 is true if the length is measured in UTF-16 code units
+	// and not in bytes.
+	lengthUTF16 bool
+}
+
+var (
 	// Reserved device names on Windows.
 	//nolint:gochecknoglobals
 	reservedNames = map[string]bool{
 		"CON": true, "PRN": true, "AUX": true, "NUL": true,
 		"COM1": true, "COM2": true, "COM3": true, "COM4": true,
 		"COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+		"COM¹": true, "COM²": true, "COM³": true,
 		"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true,
 		"LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+		"LPT¹": true, "LPT²": true, "LPT³": true,
+		"CONIN$": true, "CONOUT$": true,
+	}
+
+	//nolint:gochecknoglobals
+	filenameProfiles = map[FilenameProfile]filenameRules{
+		FilenameProfileWindows: {
+			invalidChar:              isInvalidWindowsChar,
+			validUTF8:                true,
+			reservedNames:            reservedNames,
+			noTrailingDotSpace:       true,
+			noLeadingDash:            false,
+			caseInsensitive:          true,
+			normalizationInsensitive: false,
+			maxLength:                255,
+			lengthUTF16:              true,
+		},
+		FilenameProfileMacOS: {
+			invalidChar: func(r rune) bool {
+				// Colon is shown as a slash in Finder and used as a separator by legacy APIs.
+				return r == 0 || r == '/' || r == ':'
+			},
+			validUTF8:                true,
+			reservedNames:            nil,
+			noTrailingDotSpace:       false,
+			noLeadingDash:            false,
+			caseInsensitive:          true,
+			normalizationInsensitive: true,
+			maxLength:                255,
+			lengthUTF16:              false,
+		},
+		FilenameProfileLinux: {
+			invalidChar: func(r rune) bool {
+				return r == 0 || r == '/'
+			},
+			validUTF8:                false,
+			reservedNames:            nil,
+			noTrailingDotSpace:       false,
+			noLeadingDash:            false,
+			caseInsensitive:          false,
+			normalizationInsensitive: false,
+			maxLength:                255,
+			lengthUTF16:              false,
+		},
+		FilenameProfilePOSIX: {
+			invalidChar: func(r rune) bool {
+				return (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '.' && r != '_' && r != '-'
+			},
+			validUTF8:                true,
+			reservedNames:            nil,
+			noTrailingDotSpace:       false,
+			noLeadingDash:            true,
+			caseInsensitive:          false,
+			normalizationInsensitive: false,
+			maxLength:                255,
+			lengthUTF16:              false,
+		},
+		FilenameProfileFAT32: {
+			invalidChar: func(r rune) bool {
+				return isInvalidWindowsChar(r) || r == 0x7F
+			},
+			validUTF8:                true,
+			reservedNames:            reservedNames,
+			noTrailingDotSpace:       true,
+			noLeadingDash:            false,
+			caseInsensitive:          true,
+			normalizationInsensitive: false,
+			maxLength:                255,
+			lengthUTF16:              true,
+		},
 	}
 )
 
+func isInvalidWindowsChar(r rune) bool {
+	return r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r)
+}
+
+func getFilenameRules(profile FilenameProfile) filenameRules {
+	rules, ok := filenameProfiles[profile]
+	if !ok {
+		// We default to the Windows rules.
+		return filenameProfiles[FilenameProfileWindows]
+	}
+	return rules
+}
+
+// isOnlyDots returns true if name consists only of dots.
+func isOnlyDots(name string) bool {
+	return name != "" && strings.Trim(name, ".") == ""
+}
+
+// splitReserved returns the upper case base (up to the first dot) of the name
+// and the rest of it, the extension.
+func splitReserved(name string) (string, string) {
+	if dot := strings.Index(name, "."); dot != -1 {
+		return strings.ToUpper(name[:dot]), name[dot:]
+	}
+	return strings.ToUpper(name), ""
+}
+
+// truncateUTF8 truncates s to at most n bytes, without splitting a rune.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// truncateFilename truncates name to at most maxLength bytes, preserving
+// the extension if it fits. Spaces exposed by truncation are removed.
+func truncateFilename(name string, maxLength int) string {
+	if len(name) <= maxLength {
+		return name
+	}
+	ext := ""
+	if dot := strings.LastIndex(name, "."); dot > 0 && len(name)-dot < maxLength {
+		ext = name[dot:]
+	}
+	base := strings.TrimRight(truncateUTF8(name[:len(name)-len(ext)], maxLength-len(ext)), " ")
+	if base == "" {
+		// There is no space left for the base, so we do not preserve the extension.
+		return strings.TrimRight(truncateUTF8(name, maxLength), " ")
+	}
+	return base + ext
+}
+
+// SafeFilenameOptions are options for SafeFilenameWithOptions.
+type SafeFilenameOptions struct {
+	// Profile selects the rules of a target platform. The default
+	// is Windows (NTFS) rules.
+	Profile FilenameProfile
+
+	// MaxLength is the maximum length of the filename in bytes.
+	// The filename is truncated on a rune boundary, preserving the extension.
+	// Zero means the maximum length of the profile (255 bytes for all profiles).
+	MaxLength int
+}
+
 // SafeFilename returns a safe filename for the given name.
+//
+// It applies Windows (NTFS) rules, which are the most restrictive among
+// commonly used platforms. Use SafeFilenameWithOptions for other profiles
+// and length limits.
 func SafeFilename(name string) string {
+	return SafeFilenameWithOptions(name, SafeFilenameOptions{
+		Profile:   FilenameProfileWindows,
+		MaxLength: 0,
+	})
+}
+
+// SafeFilenameWithOptions returns a safe filename for the given name, following
+// the rules of the target platform selected by the profile in options.
+//
+// Invalid characters are replaced with an underscore, reserved names are prefixed
+// with an underscore, and names which would be empty or consist only of dots
+// are replaced with an underscore.
+func SafeFilenameWithOptions(name string, options SafeFilenameOptions) string {
+	rules := getFilenameRules(options.Profile)
+	maxLength := options.MaxLength
+	if maxLength <= 0 {
+		// Profiles which measure length in UTF-16 code units allow at least
+		// as many code units as bytes we allow here.
+		maxLength = rules.maxLength
+	}
+
 	name = strings.TrimSpace(name)
 
-	// Windows does not allow trailing spaces or dots.
-	name = strings.TrimRight(name, ". ")
+	if rules.noTrailingDotSpace {
+		// Windows does not allow trailing spaces or dots.
+		name = strings.TrimRight(name, ". ")
+	}
 
-	name = invalidChars.ReplaceAllString(name, "_")
+	var b strings.Builder
+	b.Grow(len(name))
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		switch {
+		case r == utf8.RuneError && size == 1 && !rules.validUTF8:
+			// Invalid UTF-8 is copied as-is.
+			b.WriteByte(name[i])
+		case r == utf8.RuneError && size == 1, rules.invalidChar(r):
+			b.WriteByte('_')
+		default:
+			b.WriteString(name[i : i+size])
+		}
+		i += size
+	}
+	name = b.String()
 
-	// Prevent empty filename.
-	if name == "" {
+	if rules.noLeadingDash && strings.HasPrefix(name, "-") {
+		name = "_" + name[1:]
+	}
+
+	name = truncateFilename(name, maxLength)
+	// Truncation could expose trailing spaces or dots.
+	if rules.noTrailingDotSpace {
+		name = strings.TrimRight(name, ". ")
+	}
+
+	// Prevent empty filename and names which are only dots (e.g., "." and "..").
+	if name == "" || isOnlyDots(name) {
 		return "_"
 	}
 
 	// Check reserved device names (case-insensitive, without extension).
-	base := strings.ToUpper(name)
-	ext := ""
-	if dot := strings.Index(name, "."); dot != -1 {
-		base = strings.ToUpper(name[:dot])
-		ext = name[dot:]
+	base, ext := splitReserved(name)
+	if rules.reservedNames[base] {
+		name = "_" + base + ext
+		if len(name) > maxLength {
+			// We make space for the prefix by removing the last rune of the base,
+			// which also makes it not reserved anymore.
+			_, size := utf8.DecodeLastRuneInString(base)
+			name = "_" + base[:len(base)-size] + ext
+		}
+	}
+
+	return name
+}
+
+var ErrInvalidEncodedFilename = errors.Base("invalid encoded filename")
+
+// encodedEmptyFilename is how an empty name is encoded.
+const encodedEmptyFilename = "%"
+
+// isUnescapedFilenameByte returns true for bytes which EncodeFilename does not escape.
+// These are the POSIX portable filename characters without upper case letters
+// (so that encoding stays injective on case-insensitive filesystems). The dash is
+// included here, but EncodeFilename escapes it at the start of the filename.
+func isUnescapedFilenameByte(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '.' || c == '_' || c == '-'
+}
+
+// EncodeFilename encodes name into a filename which uses only characters valid under
+// all filename profiles (the percent sign aside, which is not in the POSIX portable
+// character set but is valid on all supported filesystems). Unlike SafeFilename,
+// encoding is reversible using DecodeFilename.
+//
+// Bytes other than ASCII lower case letters, digits, dots, underscores, and dashes are
+// percent-encoded as %XX (with upper case hexadecimal digits), e.g., "A" as "%41".
+// Additionally, a leading dash, a trailing dot, and the first character of a
+// reserved device name are percent-encoded. The empty name is encoded as "%".
+//
+// Because upper case letters are escaped, names which differ only in case map to
+// filenames which do not collide on case-insensitive filesystems either.
+// Encoded filenames can be up to three times longer than the name.
+//
+// The length of the encoded filename is not bounded: the encoded filename is valid
+// only if it is not longer than 255 bytes, the limit of all filename profiles, which
+// is guaranteed only for names up to 85 bytes long. Callers should bound the length
+// of names or check encoded filenames with ValidateFilename. Because encoding is
+// reversible, it cannot shorten longer names.
+func EncodeFilename(name string) string {
+	if name == "" {
+		return encodedEmptyFilename
+	}
+
+	var b strings.Builder
+	b.Grow(len(name))
+	for i := range len(name) {
+		c := name[i]
+		if !isUnescapedFilenameByte(c) || (i == 0 && c == '-') || (i == len(name)-1 && c == '.') {
+			writeEscapedFilenameByte(&b, c)
+		} else {
+			b.WriteByte(c)
+		}
 	}
+	filename := b.String()
 
+	// We check reserved names on the encoded filename. All reserved names start with
+	// an ASCII letter, which is not escaped when lower case, so we escape it.
+	base, _ := splitReserved(filename)
 	if reservedNames[base] {
-		name = "_" + base + ext
+		b.Reset()
+		writeEscapedFilenameByte(&b, filename[0])
+		b.WriteString(filename[1:])
+		filename = b.String()
 	}
 
-	return name
+	return filename
+}
+
+func writeEscapedFilenameByte(b *strings.Builder, c byte) {
+	const hexDigits = "0123456789ABCDEF"
+	b.WriteByte('%')
+	b.WriteByte(hexDigits[c>>4])
+	b.WriteByte(hexDigits[c&0x0F])
+}
+
+// DecodeFilename decodes a filename encoded by EncodeFilename back into the original name.
+//
+// It returns an error if filename is not exactly what EncodeFilename would produce,
+// so that each name has only one encoding.
+func DecodeFilename(filename string) (string, errors.E) {
+	if filename == encodedEmptyFilename {
+		return "", nil
+	}
+
+	b := make([]byte, 0, len(filename))
+	for i := 0; i < len(filename); i++ {
+		c := filename[i]
+		if c != '%' {
+			b = append(b, c)
+			continue
+		}
+		if i+2 >= len(filename) {
+			errE := errors.WithDetails(ErrInvalidEncodedFilename, "filename", filename)
+			errors.Details(errE)["position"] = i
+			return "", errE
+		}
+		d, err := strconv.ParseUint(filename[i+1:i+3], 16, 8)
+		if err != nil {
+			errE := errors.WrapWith(err, ErrInvalidEncodedFilename)
+			errors.Details(errE)["filename"] = filename
+			errors.Details(errE)["position"] = i
+			return "", errE
+		}
+		b = append(b, byte(d))
+		i += 2
+	}
+
+	name := string(b)
+	if name == "" || EncodeFilename(name) != filename {
+		return "", errors.WithDetails(ErrInvalidEncodedFilename, "filename", filename)
+	}
+	return name, nil
+}
+
+var (
+	ErrInvalidPathSegment = errors.Base("invalid path segment")
+	ErrInvalidBasePath    = errors.Base("invalid base path")
+	ErrPathEscapesBase    = errors.Base("path escapes base")
+)
+
+// checkPathSegment returns a reason why segment cannot be safely used
+// as a path segment, or an empty string if it can be.
+func checkPathSegment(segment string) string {
+	switch {
+	case strings.TrimSpace(segment) == "":
+		return "empty"
+	case strings.TrimRight(strings.TrimSpace(segment), ". ") == "":
+		// Windows ignores trailing spaces and dots, so ". ." is the same as ".".
+		return "dot segment"
+	case strings.HasPrefix(segment, `\\`) || strings.HasPrefix(segment, "//"):
+		return "UNC path"
+	case strings.HasPrefix(segment, `\`) || strings.HasPrefix(segment, "/"):
+		return "absolute path"
+	case len(segment) >= 2 && segment[1] == ':' && (('A' <= segment[0] && segment[0] <= 'Z') || ('a' <= segment[0] && segment[0] <= 'z')):
+		return "drive letter"
+	case strings.ContainsAny(segment, `/\`):
+		return "path separator"
+	}
+	return ""
+}
+
+// safePathSegments checks and sanitizes path segments.
+func safePathSegments(parts []string) ([]string, errors.E) {
+	segments := make([]string, 0, len(parts))
+	for i, part := range parts {
+		if reason := checkPathSegment(part); reason != "" {
+			errE := errors.WithDetails(ErrInvalidPathSegment, "segment", part)
+			errors.Details(errE)["index"] = i
+			errors.Details(errE)["reason"] = reason
+			return nil, errE
+		}
+		segments = append(segments, SafeFilename(part))
+	}
+	return segments, nil
+}
+
+// SafeJoin joins untrusted path segments parts to base path, returning
+// a path under base.
+//
+// Each part has to be exactly one path segment. Parts which are empty, are "." or "..",
+// contain a path separator, or are absolute paths, UNC paths, or start with a drive letter
+// are rejected with an error which reports the index of the bad part. Other parts
+// are sanitized using SafeFilename.
+func SafeJoin(base string, parts ...string) (string, errors.E) {
+	segments, errE := safePathSegments(parts)
+	if errE != nil {
+		errors.Details(errE)["base"] = base
+		return "", errE
+	}
+
+	p := filepath.Join(append([]string{base}, segments...)...)
+
+	// This should never happen, but we check anyway.
+	rel, err := filepath.Rel(base, p)
+	if err != nil || !filepath.IsLocal(rel) {
+		errE := errors.WithDetails(ErrPathEscapesBase, "base", base)
+		errors.Details(errE)["path"] = p
+		return "", errE
+	}
+
+	return p, nil
+}
+
+// SafeJoinFS is similar to SafeJoin, but it joins parts to base using
+// forward slashes and returns a path which can be used with fs.FS.
+//
+// base has to be a valid fs.FS path (see fs.ValidPath), e.g., ".".
+func SafeJoinFS(base string, parts ...string) (string, errors.E) {
+	if !fs.ValidPath(base) {
+		return "", errors.WithDetails(ErrInvalidBasePath, "base", base)
+	}
+
+	segments, errE := safePathSegments(parts)
+	if errE != nil {
+		errors.Details(errE)["base"] = base
+		return "", errE
+	}
+
+	p := path.Join(append([]string{base}, segments...)...)
+
+	// This should never happen, but we check anyway.
+	if !fs.ValidPath(p) || (base != "." && p != base && !strings.HasPrefix(p, base+"/")) {
+		errE := errors.WithDetails(ErrPathEscapesBase, "base", base)
+		errors.Details(errE)["path"] = p
+		return "", errE
+	}
+
+	return p, nil
+}
+
+// UniqueNamerSuffix selects how UniqueNamer resolves clashes.
+type UniqueNamerSuffix int
+
+const (
+	// UniqueNamerSuffixCounter appends a counter, e.g., "name (2).ext".
+	UniqueNamerSuffixCounter UniqueNamerSuffix = iota
+	// UniqueNamerSuffixHash appends a short hash of the original name, e.g., "name-1a2b3c4d.ext".
+	// If that clashes as well, a counter is appended, too. The hash is not used when
+	// MaxLength is too small to fit both the hash and a counter.
+	UniqueNamerSuffixHash
+)
+
+// UniqueNamerOptions are options for NewUniqueNamer and NewUniqueNamerFS.
+type UniqueNamerOptions struct {
+	// Profile selects the rules of a target platform. It is used to make
+	// filenames safe and to determine if filenames are compared case-insensitively
+	// and after Unicode normalization.
+	Profile FilenameProfile
+
+	// MaxLength is the maximum length of the filename in bytes, including the suffix.
+	// Zero means the maximum length of the profile. It must be at least 8 bytes,
+	// otherwise there is not enough space for suffixes.
+	MaxLength int
+
+	// Suffix selects how clashes are resolved.
+	Suffix UniqueNamerSuffix
+}
+
+// UniqueNamer issues safe filenames which are unique among all filenames it
+// has issued (and existing filenames in a directory, if created with NewUniqueNamerFS).
+//
+// It is safe for concurrent use.
+type UniqueNamer struct {
+	options UniqueNamerOptions
+	rules   filenameRules
+
+	mu    sync.Mutex
+	taken map[string]bool
+}
+
+var ErrInvalidMaxLength = errors.Base("invalid maximum length")
+
+// uniqueNamerMinLength is the smallest MaxLength UniqueNamer accepts.
+const uniqueNamerMinLength = 8
+
+// NewUniqueNamer creates a new UniqueNamer.
+//
+// It returns an error if MaxLength is positive but smaller than 8 bytes.
+func NewUniqueNamer(options UniqueNamerOptions) (*UniqueNamer, errors.E) {
+	rules := getFilenameRules(options.Profile)
+	if options.MaxLength <= 0 {
+		options.MaxLength = rules.maxLength
+	}
+	if options.MaxLength < uniqueNamerMinLength {
+		return nil, errors.WithDetails(ErrInvalidMaxLength, "maxLength", options.MaxLength)
+	}
+	return &UniqueNamer{
+		options: options,
+		rules:   rules,
+		mu:      sync.Mutex{},
+		taken:   map[string]bool{},
+	}, nil
+}
+
+// NewUniqueNamerFS creates a new UniqueNamer which also avoids names of
+// existing entries in directory dir of fsys. Use os.DirFS to check
+// a directory on disk.
+//
+// Entries are read only once, when UniqueNamer is created.
+func NewUniqueNamerFS(fsys fs.FS, dir string, options UniqueNamerOptions) (*UniqueNamer, errors.E) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.WithDetails(err, "dir", dir)
+	}
+
+	n, errE := NewUniqueNamer(options)
+	if errE != nil {
+		return nil, errE
+	}
+	for _, entry := range entries {
+		n.taken[n.key(entry.Name())] = true
+	}
+	return n, nil
+}
+
+// key returns the name under which filename is compared with other filenames.
+func (n *UniqueNamer) key(filename string) string {
+	if n.rules.normalizationInsensitive {
+		filename = norm.NFC.String(filename)
+	}
+	if n.rules.caseInsensitive {
+		filename = cases.Fold().String(filename)
+	}
+	return filename
+}
+
+// candidate returns filename with suffix added before the extension,
+// truncating the filename so that the result fits into maximum length.
+func (n *UniqueNamer) candidate(filename, suffix string) string {
+	base, ext := filename, ""
+	if dot := strings.LastIndex(filename, "."); dot > 0 {
+		base, ext = filename[:dot], filename[dot:]
+	}
+	if len(suffix)+len(ext) >= n.options.MaxLength {
+		ext = ""
+	}
+	base = truncateUTF8(base, max(0, n.options.MaxLength-len(suffix)-len(ext)))
+	return SafeFilenameWithOptions(base+suffix+ext, SafeFilenameOptions{
+		Profile:   n.options.Profile,
+		MaxLength: n.options.MaxLength,
+	})
+}
+
+// Name returns a safe filename for the given name (see SafeFilenameWithOptions)
+// which has not been issued before. Clashes are resolved by adding a suffix.
+func (n *UniqueNamer) Name(name string) string {
+	filename := SafeFilenameWithOptions(name, SafeFilenameOptions{
+		Profile:   n.options.Profile,
+		MaxLength: n.options.MaxLength,
+	})
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.take(filename) {
+		return filename
+	}
+
+	prefix := ""
+	if n.options.Suffix == UniqueNamerSuffixHash {
+		sum := sha256.Sum256([]byte(name))
+		hash := "-" + hex.EncodeToString(sum[:4])
+		// We use the hash only if at least half of MaxLength remains for the name and a counter.
+		if 2*len(hash) <= n.options.MaxLength {
+			prefix = hash
+			candidate := n.candidate(filename, prefix)
+			if n.take(candidate) {
+				return candidate
+			}
+		}
+	}
+
+	for i := 2; ; i++ {
+		suffix := prefix + " (" + strconv.Itoa(i) + ")"
+		if len(suffix) > n.options.MaxLength {
+			// There is no space left for the suffix, so the counter alone is used as the name.
+			suffix = strconv.Itoa(i)
+		}
+		candidate := n.candidate(filename, suffix)
+		if n.take(candidate) {
+			return candidate
+		}
+	}
+}
+
+// take marks filename as taken and returns true, if it is not already taken.
+func (n *UniqueNamer) take(filename string) bool {
+	k := n.key(filename)
+	if n.taken[k] {
+		return false
+	}
+	n.taken[k] = true
+	return true
+}
+
+var (
+	ErrInvalidFilename          = errors.Base("invalid filename")
+	ErrFilenameEmpty            = errors.BaseWrap(ErrInvalidFilename, "empty filename")
+	ErrFilenameDotsOnly         = errors.BaseWrap(ErrInvalidFilename, "filename consists only of dots")
+	ErrFilenameInvalidCharacter = errors.BaseWrap(ErrInvalidFilename, "invalid character in filename")
+	ErrFilenameControlCharacter = errors.BaseWrap(ErrInvalidFilename, "control character in filename")
+	ErrFilenameInvalidUTF8      = errors.BaseWrap(ErrInvalidFilename, "invalid UTF-8 in filename")
+	ErrFilenameReservedName     = errors.BaseWrap(ErrInvalidFilename, "reserved filename")
+	ErrFilenameTrailingDotSpace = errors.BaseWrap(ErrInvalidFilename, "trailing dot or space in filename")
+	ErrFilenameLeadingDash      = errors.BaseWrap(ErrInvalidFilename, "leading dash in filename")
+	ErrFilenameTooLong          = errors.BaseWrap(ErrInvalidFilename, "filename too long")
+)
+
+// filenameLength returns the length of filename as measured by rules.
+func filenameLength(filename string, rules filenameRules) int {
+	if rules.lengthUTF16 {
+		return len(utf16.Encode([]rune(filename)))
+	}
+	return len(filename)
+}
+
+// ValidateFilename validates that name is a valid filename under the rules of
+// the target platform selected by the profile. These are the same rules
+// SafeFilenameWithOptions uses to make filenames safe.
+//
+// It returns nil if name is valid. Otherwise it returns an error which joins
+// errors for all violations found, each with its position (byte offset)
+// in name as an error detail. Use errors.Unjoin to obtain them.
+// All of them wrap ErrInvalidFilename.
+func ValidateFilename(name string, profile FilenameProfile) errors.E {
+	rules := getFilenameRules(profile)
+
+	violations := []error{}
+	violation := func(err error, position int) errors.E {
+		errE := errors.WithDetails(err, "position", position)
+		violations = append(violations, errE)
+		return errE
+	}
+
+	if name == "" {
+		violation(ErrFilenameEmpty, 0)
+	} else if isOnlyDots(name) {
+		violation(ErrFilenameDotsOnly, 0)
+	}
+
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			if rules.validUTF8 {
+				errE := violation(ErrFilenameInvalidUTF8, i)
+				errors.Details(errE)["byte"] = name[i]
+			}
+		case rules.invalidChar(r) && unicode.IsControl(r):
+			errE := violation(ErrFilenameControlCharacter, i)
+			errors.Details(errE)["character"] = string(r)
+		case rules.invalidChar(r):
+			errE := violation(ErrFilenameInvalidCharacter, i)
+			errors.Details(errE)["character"] = string(r)
+		}
+		i += size
+	}
+
+	if rules.noLeadingDash && strings.HasPrefix(name, "-") {
+		violation(ErrFilenameLeadingDash, 0)
+	}
+
+	if rules.noTrailingDotSpace && !isOnlyDots(name) {
+		if trimmed := strings.TrimRight(name, ". "); len(trimmed) < len(name) {
+			violation(ErrFilenameTrailingDotSpace, len(trimmed))
+		}
+	}
+
+	if base, _ := splitReserved(name); rules.reservedNames[base] {
+		errE := violation(ErrFilenameReservedName, 0)
+		errors.Details(errE)["reserved"] = base
+	}
+
+	if length := filenameLength(name, rules); length > rules.maxLength {
+		errE := violation(ErrFilenameTooLong, 0)
+		errors.Details(errE)["length"] = length
+		errors.Details(errE)["maxLength"] = rules.maxLength
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	// We use standard Join so that violations are joined even if there is only one.
+	return errors.WithDetails(stderrors.Join(violations...), "name", name, "profile", profile.String())
 }
@@ -1,9 +1,17 @@
 package x_test
 
 import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/tozd/go/errors"
 
 	"gitlab.com/tozd/go/x"
 )
@@ -353,3 +361,512 @@ func TestSafeFilename(t *testing.T) { //nolint:maintidx
 		})
 	}
 }
+
+func TestSafeFilenameWithOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		input     string
+		profile   x.FilenameProfile
+		maxLength int
+		expected  string
+	}{
+		{"windows superscript COM", "COM¹.txt", x.FilenameProfileWindows, 0, "_COM¹.txt"},
+		{"windows superscript LPT", "lpt³", x.FilenameProfileWindows, 0, "_LPT³"},
+		{"windows CONIN$", "conin$", x.FilenameProfileWindows, 0, "_CONIN$"},
+		{"windows invalid UTF-8", "a\xffb", x.FilenameProfileWindows, 0, "a_b"},
+		{"windows dots only", "..", x.FilenameProfileWindows, 0, "_"},
+		{"fat32 DEL", "a\x7fb", x.FilenameProfileFAT32, 0, "a_b"},
+		{"fat32 reserved", "NUL.txt", x.FilenameProfileFAT32, 0, "_NUL.txt"},
+		{"macos colon", "a:b/c", x.FilenameProfileMacOS, 0, "a_b_c"},
+		{"macos allows other chars", `a<b>c?.txt.`, x.FilenameProfileMacOS, 0, `a<b>c?.txt.`},
+		{"macos reserved allowed", "CON", x.FilenameProfileMacOS, 0, "CON"},
+		{"macos dot", ".", x.FilenameProfileMacOS, 0, "_"},
+		{"macos dot dot", "..", x.FilenameProfileMacOS, 0, "_"},
+		{"linux slash", "a/b\\c", x.FilenameProfileLinux, 0, "a_b\\c"},
+		{"linux invalid UTF-8", "a\xffb", x.FilenameProfileLinux, 0, "a\xffb"},
+		{"linux dots only", "...", x.FilenameProfileLinux, 0, "_"},
+		{"linux leading dash", "-rf", x.FilenameProfileLinux, 0, "-rf"},
+		{"posix leading dash", "-rf", x.FilenameProfilePOSIX, 0, "_rf"},
+		{"posix non-ASCII", "čaj je 100%.txt", x.FilenameProfilePOSIX, 0, "_aj_je_100_.txt"},
+		{"max length", "abcdefghij.txt", x.FilenameProfileLinux, 10, "abcdef.txt"},
+		{"max length no extension", "abcdefghijklmn", x.FilenameProfileLinux, 10, "abcdefghij"},
+		{"max length long extension", "abc.defghijklmn", x.FilenameProfileLinux, 10, "abc.defghi"},
+		{"max length rune boundary", "čččččč.txt", x.FilenameProfileLinux, 9, "čč.txt"},
+		{"max length no space for base", "ččč.txt", x.FilenameProfileLinux, 5, "čč"},
+		{"max length fits", "čččččč.txt", x.FilenameProfileLinux, 16, "čččččč.txt"},
+		{"max length trailing space", "abc defgh.txt", x.FilenameProfileWindows, 8, "abc.txt"},
+		{"max length reserved", "CONSOLE.txt", x.FilenameProfileWindows, 7, "_CO.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := x.SafeFilenameWithOptions(tt.input, x.SafeFilenameOptions{
+				Profile:   tt.profile,
+				MaxLength: tt.maxLength,
+			})
+			assert.Equal(t, tt.expected, result)
+			if tt.maxLength > 0 {
+				assert.LessOrEqual(t, len(result), tt.maxLength)
+			}
+		})
+	}
+}
+
+func TestEncodeFilename(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"file.txt", "file.txt"},
+		{"my_file-name.txt", "my_file-name.txt"},
+		{"", "%"},
+		{"%", "%25"},
+		{"a/b", "a%2Fb"},
+		{`a\b`, "a%5Cb"},
+		{"a b", "a%20b"},
+		{"file.txt.", "file.txt%2E"},
+		{".", "%2E"},
+		{"..", ".%2E"},
+		{".hidden", ".hidden"},
+		{"-rf", "%2Drf"},
+		{"a-b", "a-b"},
+		{"A", "%41"},
+		{"Readme.TXT", "%52eadme.%54%58%54"},
+		{"CON", "%43%4F%4E"},
+		{"con.txt", "%63on.txt"},
+		{"con2", "con2"},
+		{"CON2", "%43%4F%4E2"},
+		{"com¹", "com%C2%B9"},
+		{"čaj", "%C4%8Daj"},
+		{"a\x00b", "a%00b"},
+		{"a\xffb", "a%FFb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			encoded := x.EncodeFilename(tt.input)
+			assert.Equal(t, tt.expected, encoded)
+
+			decoded, errE := x.DecodeFilename(encoded)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, tt.input, decoded)
+
+			// Encoded filenames are already safe.
+			for _, profile := range []x.FilenameProfile{
+				x.FilenameProfileWindows,
+				x.FilenameProfileMacOS,
+				x.FilenameProfileLinux,
+				x.FilenameProfileFAT32,
+			} {
+				assert.Equal(t, encoded, x.SafeFilenameWithOptions(encoded, x.SafeFilenameOptions{Profile: profile, MaxLength: 0}), profile.String())
+			}
+		})
+	}
+}
+
+func TestEncodeFilenameCaseInsensitive(t *testing.T) {
+	t.Parallel()
+
+	names := []string{"a", "A", "readme.txt", "README.TXT", "ReadMe.txt", "con", "CON", "Con", "%41", "%4a", "J", "j"}
+	seen := map[string]string{}
+	for _, name := range names {
+		folded := strings.ToLower(x.EncodeFilename(name))
+		other, ok := seen[folded]
+		assert.False(t, ok, "%q and %q collide", name, other)
+		seen[folded] = name
+	}
+}
+
+func TestEncodeFilenameLength(t *testing.T) {
+	t.Parallel()
+
+	// Names up to 85 bytes are always encoded into filenames which are not too long.
+	// The POSIX profile does not allow the percent sign.
+	filename := x.EncodeFilename(strings.Repeat("A", 85))
+	assert.Len(t, filename, 255)
+	for _, profile := range []x.FilenameProfile{x.FilenameProfileWindows, x.FilenameProfileMacOS, x.FilenameProfileLinux} {
+		errE := x.ValidateFilename(filename, profile)
+		require.NoError(t, errE, "% -+#.1v", errE)
+	}
+
+	// Longer names can produce filenames which are too long.
+	filename = x.EncodeFilename(strings.Repeat("A", 86))
+	assert.Len(t, filename, 258)
+	errE := x.ValidateFilename(filename, x.FilenameProfileLinux)
+	assert.ErrorIs(t, errE, x.ErrFilenameTooLong)
+}
+
+func TestDecodeFilenameErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []string{
+		"",
+		"%2",
+		"abc%",
+		"%zz",
+		// Not canonical.
+		"%2e",
+		"%61bc",
+		"a b",
+		"CON",
+		"A",
+		"file.",
+		"-rf",
+		"%%",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt, func(t *testing.T) {
+			t.Parallel()
+
+			_, errE := x.DecodeFilename(tt)
+			assert.ErrorIs(t, errE, x.ErrInvalidEncodedFilename)
+		})
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	t.Parallel()
+
+	base := filepath.Join("base", "dir")
+
+	tests := []struct {
+		parts    []string
+		expected string
+	}{
+		{nil, base},
+		{[]string{"user"}, filepath.Join(base, "user")},
+		{[]string{"user", "project", "file.txt"}, filepath.Join(base, "user", "project", "file.txt")},
+		{[]string{"us:er", "CON", "file?.txt."}, filepath.Join(base, "us_er", "_CON", "file_.txt")},
+		{[]string{".hidden"}, filepath.Join(base, ".hidden")},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.parts, "|"), func(t *testing.T) {
+			t.Parallel()
+
+			p, errE := x.SafeJoin(base, tt.parts...)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, tt.expected, p)
+		})
+	}
+}
+
+func TestSafeJoinErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		parts  []string
+		index  int
+		reason string
+	}{
+		{[]string{""}, 0, "empty"},
+		{[]string{"a", "  "}, 1, "empty"},
+		{[]string{"a", "b", ".."}, 2, "dot segment"},
+		{[]string{"."}, 0, "dot segment"},
+		{[]string{". ."}, 0, "dot segment"},
+		{[]string{"a", "/etc"}, 1, "absolute path"},
+		{[]string{`\Windows`}, 0, "absolute path"},
+		{[]string{`\\server\share`}, 0, "UNC path"},
+		{[]string{"//server/share"}, 0, "UNC path"},
+		{[]string{"C:"}, 0, "drive letter"},
+		{[]string{`c:\Windows`}, 0, "drive letter"},
+		{[]string{"a/../../etc"}, 0, "path separator"},
+		{[]string{`a\b`}, 0, "path separator"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.parts, "|"), func(t *testing.T) {
+			t.Parallel()
+
+			_, errE := x.SafeJoin("base", tt.parts...)
+			require.ErrorIs(t, errE, x.ErrInvalidPathSegment)
+			assert.Equal(t, tt.index, errors.Details(errE)["index"])
+			assert.Equal(t, tt.reason, errors.Details(errE)["reason"])
+			assert.Equal(t, tt.parts[tt.index], errors.Details(errE)["segment"])
+
+			_, errE = x.SafeJoinFS("base", tt.parts...)
+			require.ErrorIs(t, errE, x.ErrInvalidPathSegment)
+			assert.Equal(t, tt.index, errors.Details(errE)["index"])
+		})
+	}
+}
+
+func TestSafeJoinFS(t *testing.T) {
+	t.Parallel()
+
+	p, errE := x.SafeJoinFS(".", "user", "project:1", "file.txt")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "user/project_1/file.txt", p)
+	assert.True(t, fs.ValidPath(p))
+
+	p, errE = x.SafeJoinFS("data/users", "user")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "data/users/user", p)
+
+	p, errE = x.SafeJoinFS(".")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, ".", p)
+
+	for _, base := range []string{"", "/abs", "../up", "a/../b", "a/"} {
+		_, errE = x.SafeJoinFS(base, "user")
+		assert.ErrorIs(t, errE, x.ErrInvalidBasePath, base)
+	}
+}
+
+func TestUniqueNamer(t *testing.T) {
+	t.Parallel()
+
+	namer, errE := x.NewUniqueNamer(x.UniqueNamerOptions{
+		Profile:   x.FilenameProfileWindows,
+		MaxLength: 0,
+		Suffix:    x.UniqueNamerSuffixCounter,
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	assert.Equal(t, "file.txt", namer.Name("file.txt"))
+	assert.Equal(t, "file (2).txt", namer.Name("file.txt"))
+	assert.Equal(t, "File (3).TXT", namer.Name("File.TXT."))
+	assert.Equal(t, "a_b", namer.Name("a/b"))
+	assert.Equal(t, "a_b (2)", namer.Name(`a\b`))
+	assert.Equal(t, ".hidden", namer.Name(".hidden"))
+	assert.Equal(t, ".hidden (2)", namer.Name(".hidden"))
+}
+
+func TestUniqueNamerProfiles(t *testing.T) {
+	t.Parallel()
+
+	// NFC and NFD forms of "é".
+	nfc := "\u00e9.txt"
+	nfd := "e\u0301.txt"
+
+	linux, errE := x.NewUniqueNamer(x.UniqueNamerOptions{Profile: x.FilenameProfileLinux, MaxLength: 0, Suffix: x.UniqueNamerSuffixCounter})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "File.txt", linux.Name("File.txt"))
+	assert.Equal(t, "file.txt", linux.Name("file.txt"))
+	assert.Equal(t, nfc, linux.Name(nfc))
+	assert.Equal(t, nfd, linux.Name(nfd))
+
+	macos, errE := x.NewUniqueNamer(x.UniqueNamerOptions{Profile: x.FilenameProfileMacOS, MaxLength: 0, Suffix: x.UniqueNamerSuffixCounter})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "File.txt", macos.Name("File.txt"))
+	assert.Equal(t, "file (2).txt", macos.Name("file.txt"))
+	assert.Equal(t, nfc, macos.Name(nfc))
+	assert.Equal(t, "e\u0301 (2).txt", macos.Name(nfd))
+	assert.Equal(t, "STRASSE", macos.Name("STRASSE"))
+	assert.Equal(t, "straße (2)", macos.Name("straße"))
+
+	posix, errE := x.NewUniqueNamer(x.UniqueNamerOptions{Profile: x.FilenameProfilePOSIX, MaxLength: 0, Suffix: x.UniqueNamerSuffixCounter})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "file.txt", posix.Name("file.txt"))
+	assert.Equal(t, "file__2_.txt", posix.Name("file.txt"))
+}
+
+func TestUniqueNamerHash(t *testing.T) {
+	t.Parallel()
+
+	namer, errE := x.NewUniqueNamer(x.UniqueNamerOptions{Profile: x.FilenameProfileWindows, MaxLength: 0, Suffix: x.UniqueNamerSuffixHash})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	assert.Equal(t, "a_b.txt", namer.Name("a/b.txt"))
+	assert.Regexp(t, `^a_b-[0-9a-f]{8}\.txt$`, namer.Name(`a\b.txt`))
+	assert.Regexp(t, `^a_b-[0-9a-f]{8} \(2\)\.txt$`, namer.Name(`a\b.txt`))
+}
+
+func TestUniqueNamerMaxLength(t *testing.T) {
+	t.Parallel()
+
+	namer, errE := x.NewUniqueNamer(x.UniqueNamerOptions{Profile: x.FilenameProfileWindows, MaxLength: 12, Suffix: x.UniqueNamerSuffixCounter})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	assert.Equal(t, "abcdefgh.txt", namer.Name("abcdefghijkl.txt"))
+	assert.Equal(t, "abcd (2).txt", namer.Name("abcdefghijkl.txt"))
+	for range 10 {
+		assert.LessOrEqual(t, len(namer.Name("abcdefghijkl.txt")), 12)
+	}
+}
+
+func TestUniqueNamerSmallMaxLength(t *testing.T) {
+	t.Parallel()
+
+	for _, maxLength := range []int{1, 3, 5, 7} {
+		_, errE := x.NewUniqueNamer(x.UniqueNamerOptions{Profile: x.FilenameProfileWindows, MaxLength: maxLength, Suffix: x.UniqueNamerSuffixCounter})
+		assert.ErrorIs(t, errE, x.ErrInvalidMaxLength, maxLength)
+	}
+
+	_, errE := x.NewUniqueNamerFS(fstest.MapFS{}, ".", x.UniqueNamerOptions{Profile: x.FilenameProfileWindows, MaxLength: 3, Suffix: x.UniqueNamerSuffixHash})
+	assert.ErrorIs(t, errE, x.ErrInvalidMaxLength)
+
+	for _, maxLength := range []int{8, 9, 17, 18} {
+		for _, suffix := range []x.UniqueNamerSuffix{x.UniqueNamerSuffixCounter, x.UniqueNamerSuffixHash} {
+			namer, errE := x.NewUniqueNamer(x.UniqueNamerOptions{Profile: x.FilenameProfileWindows, MaxLength: maxLength, Suffix: suffix})
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			names := map[string]bool{}
+			for range 200 {
+				name := namer.Name("abcdefgh.txt")
+				assert.LessOrEqual(t, len(name), maxLength, name)
+				assert.NotEmpty(t, name)
+				assert.False(t, names[strings.ToLower(name)], name)
+				names[strings.ToLower(name)] = true
+			}
+		}
+	}
+
+	namer, errE := x.NewUniqueNamer(x.UniqueNamerOptions{Profile: x.FilenameProfileWindows, MaxLength: 8, Suffix: x.UniqueNamerSuffixCounter})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "abcd.txt", namer.Name("abcdefgh.txt"))
+	assert.Equal(t, "abcd (2)", namer.Name("abcdefgh.txt"))
+}
+
+func TestUniqueNamerFS(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"dir/File.txt":  &fstest.MapFile{},
+		"dir/other.txt": &fstest.MapFile{},
+	}
+
+	namer, errE := x.NewUniqueNamerFS(fsys, "dir", x.UniqueNamerOptions{Profile: x.FilenameProfileWindows, MaxLength: 0, Suffix: x.UniqueNamerSuffixCounter})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "file (2).txt", namer.Name("file.txt"))
+	assert.Equal(t, "new.txt", namer.Name("new.txt"))
+
+	_, errE = x.NewUniqueNamerFS(fsys, "missing", x.UniqueNamerOptions{Profile: x.FilenameProfileWindows, MaxLength: 0, Suffix: x.UniqueNamerSuffixCounter})
+	assert.ErrorIs(t, errE, fs.ErrNotExist)
+}
+
+func TestUniqueNamerConcurrent(t *testing.T) {
+	t.Parallel()
+
+	namer, errE := x.NewUniqueNamer(x.UniqueNamerOptions{Profile: x.FilenameProfileWindows, MaxLength: 0, Suffix: x.UniqueNamerSuffixCounter})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	const goroutines = 10
+	const names = 50
+
+	var mu sync.Mutex
+	issued := map[string]bool{}
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Go(func() {
+			for range names {
+				name := namer.Name("file.txt")
+				mu.Lock()
+				assert.False(t, issued[name], name)
+				issued[name] = true
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	assert.Len(t, issued, goroutines*names)
+}
+
+func TestValidateFilename(t *testing.T) {
+	t.Parallel()
+
+	type violation struct {
+		err      error
+		position int
+	}
+
+	tests := []struct {
+		name       string
+		profile    x.FilenameProfile
+		violations []violation
+	}{
+		{"file.txt", x.FilenameProfileWindows, nil},
+		{".hidden", x.FilenameProfileWindows, nil},
+		{"", x.FilenameProfileWindows, []violation{{x.ErrFilenameEmpty, 0}}},
+		{"..", x.FilenameProfileLinux, []violation{{x.ErrFilenameDotsOnly, 0}}},
+		{"...", x.FilenameProfileWindows, []violation{{x.ErrFilenameDotsOnly, 0}}},
+		{"a<b>.txt", x.FilenameProfileWindows, []violation{{x.ErrFilenameInvalidCharacter, 1}, {x.ErrFilenameInvalidCharacter, 3}}},
+		{"a<b>.txt", x.FilenameProfileLinux, nil},
+		{"a\x01b", x.FilenameProfileWindows, []violation{{x.ErrFilenameControlCharacter, 1}}},
+		{"a\x01b", x.FilenameProfileLinux, nil},
+		{"a\x00b", x.FilenameProfileLinux, []violation{{x.ErrFilenameControlCharacter, 1}}},
+		{"a\xffb", x.FilenameProfileWindows, []violation{{x.ErrFilenameInvalidUTF8, 1}}},
+		{"a\xffb", x.FilenameProfileLinux, nil},
+		{"file. ", x.FilenameProfileWindows, []violation{{x.ErrFilenameTrailingDotSpace, 4}}},
+		{"file. ", x.FilenameProfileMacOS, nil},
+		{"con.txt", x.FilenameProfileWindows, []violation{{x.ErrFilenameReservedName, 0}}},
+		{"COM¹", x.FilenameProfileFAT32, []violation{{x.ErrFilenameReservedName, 0}}},
+		{"con.txt", x.FilenameProfileLinux, nil},
+		{"-rf", x.FilenameProfilePOSIX, []violation{{x.ErrFilenameLeadingDash, 0}}},
+		{"-rf", x.FilenameProfileLinux, nil},
+		{"čaj", x.FilenameProfilePOSIX, []violation{{x.ErrFilenameInvalidCharacter, 0}}},
+		{strings.Repeat("a", 256), x.FilenameProfileLinux, []violation{{x.ErrFilenameTooLong, 0}}},
+		// 200 runes are 400 bytes but 200 UTF-16 code units.
+		{strings.Repeat("č", 200), x.FilenameProfileWindows, nil},
+		{strings.Repeat("č", 200), x.FilenameProfileLinux, []violation{{x.ErrFilenameTooLong, 0}}},
+		{`AUX:|.`, x.FilenameProfileWindows, []violation{
+			{x.ErrFilenameInvalidCharacter, 3},
+			{x.ErrFilenameInvalidCharacter, 4},
+			{x.ErrFilenameTrailingDotSpace, 5},
+		}},
+		{"nul. ", x.FilenameProfileWindows, []violation{
+			{x.ErrFilenameTrailingDotSpace, 3},
+			{x.ErrFilenameReservedName, 0},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%q", tt.profile, tt.name), func(t *testing.T) {
+			t.Parallel()
+
+			errE := x.ValidateFilename(tt.name, tt.profile)
+			if tt.violations == nil {
+				assert.NoError(t, errE, "% -+#.1v", errE)
+				return
+			}
+
+			require.Error(t, errE)
+			assert.ErrorIs(t, errE, x.ErrInvalidFilename)
+			assert.Equal(t, tt.name, errors.Details(errE)["name"])
+			assert.Equal(t, tt.profile.String(), errors.Details(errE)["profile"])
+			violations := errors.Unjoin(errE)
+			require.Len(t, violations, len(tt.violations), "% -+#.1v", errE)
+			for i, v := range tt.violations {
+				assert.ErrorIs(t, violations[i], v.err)
+				assert.Equal(t, v.position, errors.Details(violations[i])["position"])
+			}
+		})
+	}
+}
+
+// TestValidateSafeFilename checks that SafeFilenameWithOptions and ValidateFilename agree.
+func TestValidateSafeFilename(t *testing.T) {
+	t.Parallel()
+
+	inputs := []string{
+		"", " ", ".", "..", "...", " . . ", "file.txt", "file.txt. . .", `file<>:"/\|?*name.txt`,
+		"file\x00\x01\x1F\x7Fname", "a\xffb", "CON", "con.txt", "COM¹.tar.gz", "-rf", "čaj je 100%.txt",
+		"CONSOLE.txt", strings.Repeat("a", 300) + ".txt", strings.Repeat("č", 300), "é", "é",
+	}
+
+	for _, profile := range []x.FilenameProfile{
+		x.FilenameProfileWindows,
+		x.FilenameProfileMacOS,
+		x.FilenameProfileLinux,
+		x.FilenameProfilePOSIX,
+		x.FilenameProfileFAT32,
+	} {
+		for _, input := range inputs {
+			for _, maxLength := range []int{0, 7} {
+				safe := x.SafeFilenameWithOptions(input, x.SafeFilenameOptions{Profile: profile, MaxLength: maxLength})
+				errE := x.ValidateFilename(safe, profile)
+				assert.NoError(t, errE, "%s %q %d: % -+#.1v", profile, input, maxLength, errE)
+			}
+		}
+	}
+}
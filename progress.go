@@ -131,10 +131,28 @@ const notEstimated = -1 * time.Second
 //		Count() int64
 //	}
 func NewTicker(ctx context.Context, count, size counter, interval time.Duration) *Ticker {
+	return NewTickerWithOptions(ctx, count, size, interval, TickerOptions{
+		Clock: nil,
+	})
+}
+
+// TickerOptions are options for NewTickerWithOptions.
+type TickerOptions struct {
+	// Clock to use. If nil, SystemClock is used.
+	Clock Clock
+}
+
+// NewTickerWithOptions is like NewTicker, but with options.
+func NewTickerWithOptions(ctx context.Context, count, size counter, interval time.Duration, options TickerOptions) *Ticker {
+	clock := options.Clock
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
-	started := time.Now()
+	started := clock.Now()
 	output := make(chan Progress)
-	ticker := time.NewTicker(interval)
+	ticker := clock.NewTicker(interval)
 	go func() {
 		defer cancel()
 		defer close(output)
@@ -149,7 +167,7 @@ func NewTicker(ctx context.Context, count, size counter, interval time.Duration)
 			select {
 			case <-ctx.Done():
 				return
-			case now := <-ticker.C:
+			case now := <-ticker.Chan():
 				c := count.Count()
 				s := size.Count()
 				if s != prevSize {
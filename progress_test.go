@@ -139,67 +139,93 @@ func TestTicker(t *testing.T) {
 	}
 }
 
-func TestTickerSizeChange(t *testing.T) {
+func TestTickerManualClock(t *testing.T) {
 	t.Parallel()
 
-	ctx, cancel := context.WithCancel(t.Context())
-	defer cancel()
+	started := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := x.NewManualClock(started)
 
 	count := x.NewCounter(0)
 	size := x.NewCounter(10)
 
-	ticker := x.NewTicker(ctx, count, size, tickerInterval)
+	ticker := x.NewTickerWithOptions(t.Context(), count, size, time.Second, x.TickerOptions{Clock: clock})
 	require.NotNil(t, ticker)
 	defer ticker.Stop()
 
-	l := sync.Mutex{}
-	progress := []x.Progress{}
+	assert.Equal(t, 1, clock.Tickers())
 
-	go func() {
-		for p := range ticker.C {
-			func() {
-				l.Lock()
-				defer l.Unlock()
-				progress = append(progress, p)
-			}()
-		}
-	}()
+	// Before any progress, the estimate is not available.
+	clock.Advance(time.Second)
+	p := <-ticker.C
+	assert.Equal(t, int64(10), p.Size)
+	assert.Equal(t, int64(0), p.Count)
+	assert.Equal(t, started, p.Started)
+	assert.Equal(t, started.Add(time.Second), p.Current)
+	assert.Equal(t, time.Second, p.Elapsed)
+	assert.Negative(t, p.Remaining())
+	assert.True(t, p.Estimated().IsZero())
 
-	last := func() x.Progress {
-		l.Lock()
-		defer l.Unlock()
-		require.NotEmpty(t, progress)
-		return progress[len(progress)-1]
-	}
+	count.Add(4)
+	clock.Advance(time.Second)
+	p = <-ticker.C
+	assert.Equal(t, int64(4), p.Count)
+	assert.Equal(t, 40.0, p.Percent()) //nolint:testifylint
+	assert.Equal(t, 2*time.Second, p.Elapsed)
+	assert.Equal(t, 3*time.Second, p.Remaining())
+	assert.Equal(t, started.Add(5*time.Second), p.Estimated())
+
+	ticker.Stop()
+
+	// Channel should be closed.
+	_, ok := <-ticker.C
+	assert.False(t, ok)
+	assert.Equal(t, 0, clock.Tickers())
+}
+
+func TestTickerSizeChange(t *testing.T) {
+	t.Parallel()
+
+	started := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := x.NewManualClock(started)
+
+	count := x.NewCounter(0)
+	size := x.NewCounter(10)
+
+	ticker := x.NewTickerWithOptions(t.Context(), count, size, time.Second, x.TickerOptions{Clock: clock})
+	require.NotNil(t, ticker)
+	defer ticker.Stop()
 
 	// With some progress made, the estimate is available.
 	count.Add(4)
-	time.Sleep(2 * tickerInterval)
+	clock.Advance(time.Second)
 
-	p := last()
+	p := <-ticker.C
 	assert.Equal(t, int64(10), p.Size)
 	assert.Equal(t, int64(4), p.Count)
-	assert.Positive(t, p.Remaining())
-	assert.False(t, p.Estimated().IsZero())
+	assert.Equal(t, 1500*time.Millisecond, p.Remaining())
+	assert.Equal(t, started.Add(2500*time.Millisecond), p.Estimated())
 
 	// Size changes (more work is discovered) without any further progress. The estimate is
 	// reset and, with no progress since the change, it is not available anymore.
 	size.Add(10)
-	time.Sleep(2 * tickerInterval)
+	clock.Advance(time.Second)
 
-	p = last()
+	p = <-ticker.C
 	assert.Equal(t, int64(20), p.Size)
 	assert.Equal(t, int64(4), p.Count)
 	assert.Negative(t, p.Remaining())
 	assert.True(t, p.Estimated().IsZero())
 
-	// Once there is progress after the size change, the estimate is available again.
+	// Once there is progress after the size change, the estimate is available again,
+	// computed only from the progress made after the change.
 	count.Add(8)
-	time.Sleep(2 * tickerInterval)
+	clock.Advance(time.Second)
 
-	p = last()
+	p = <-ticker.C
 	assert.Equal(t, int64(20), p.Size)
 	assert.Equal(t, int64(12), p.Count)
-	assert.Positive(t, p.Remaining())
-	assert.False(t, p.Estimated().IsZero())
+	assert.Equal(t, time.Second, p.Remaining())
+	assert.Equal(t, started.Add(4*time.Second), p.Estimated())
+	// Elapsed covers the whole run.
+	assert.Equal(t, 3*time.Second, p.Elapsed)
 }
@@ -1,7 +1,20 @@
 package x
 
 import (
+	"context"
+	"math"
 	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+var (
+	ErrInvalidRat           = errors.Base("invalid rational number")
+	ErrRatPrecisionExceeded = errors.Base("rational number precision exceeds limit")
 )
 
 var (
@@ -12,6 +25,29 @@ var (
 	tenInt  = big.NewInt(10) //nolint:mnd,gochecknoglobals
 )
 
+// ratPrecisionLoopLimit is the largest denominator for which RatPrecisionContext
+// finds the number of repeating digits by direct iteration instead of by factorization.
+// It is also how often the context is checked during direct iteration.
+const ratPrecisionLoopLimit = 1 << 12
+
+// ratPrecisionMaxDigitsLoopLimit is the largest maxDigits for which RatPrecisionContext
+// finds the number of repeating digits by direct iteration, bounding the work by maxDigits.
+const ratPrecisionMaxDigitsLoopLimit = 1 << 20
+
+// ratTrialDivisionLimit is the limit for trial division before using Pollard's rho.
+const ratTrialDivisionLimit = 1 << 10
+
+// ratFactorizationBitsLimit is the largest bit length of the denominator (without
+// factors 2 and 5) which RatPrecisionContext attempts to factorize.
+const ratFactorizationBitsLimit = 1 << 9
+
+// ratFactorizationStepsLimit is the largest number of Pollard's rho steps
+// RatPrecisionContext does in total when factorizing.
+const ratFactorizationStepsLimit = 1 << 16
+
+// errFactorizationLimit is returned by factorize when it reaches ratFactorizationStepsLimit.
+var errFactorizationLimit = errors.Base("factorization limit reached")
+
 // RatPrecision computes for rat the number of non-repeating digits on the right
 // of the decimal point and the number of repeating digits which cyclicly follow.
 //
@@ -19,51 +55,635 @@ var (
 // representation, when there are no repeating digits.
 //
 // This is similar to Rat.FloatPrec but returns also the number of repeating digits
-// following the non-repeating digits. Rat.FloatPrec is also much faster.
+// following the non-repeating digits.
+//
+// Computing the number of repeating digits requires factorization of the denominator,
+// which can take very long for some large denominators, so the work RatPrecision does is
+// bounded (see RatPrecisionContext). If the number of repeating digits cannot be determined
+// within that bound or if it does not fit into int, RatPrecision returns -1 for it.
 func RatPrecision(rat *big.Rat) (int, int) {
+	nonRepeating, repeating, errE := RatPrecisionContext(context.Background(), rat, 0)
+	if errors.Is(errE, ErrRatPrecisionExceeded) {
+		return errors.Details(errE)["nonRepeating"].(int), -1 //nolint:forcetypeassert,errcheck
+	} else if errE != nil {
+		panic(errE)
+	}
+	return nonRepeating, repeating
+}
+
+// RatPrecisionContext is like RatPrecision, but it stops early and returns
+// an error if ctx is canceled or if the number of non-repeating or repeating
+// digits is larger than maxDigits. If maxDigits is 0, there is no limit
+// on the number of digits.
+//
+// The number of repeating digits is the multiplicative order of 10 modulo the denominator
+// without factors 2 and 5. It is found using Carmichael's function of the denominator,
+// which requires factorization of the denominator. When the denominator is small or when
+// maxDigits is at most 1048576 (2^20), the multiplicative order is searched for directly instead,
+// so the work is bounded by maxDigits. For larger or no maxDigits, the work factorization does
+// is bounded by a fixed limit: denominators (without factors 2 and 5) larger than 512 bits are
+// not factorized, and factorization is stopped after 65536 (2^16) steps of Pollard's rho algorithm.
+// In both cases ErrRatPrecisionExceeded is returned.
+func RatPrecisionContext(ctx context.Context, rat *big.Rat, maxDigits int) (int, int, errors.E) {
 	// Go assures that rat is normalized.
 	m := new(big.Int).Set(rat.Denom())
 
+	k := int(m.TrailingZeroBits()) //nolint:gosec
+	m.Rsh(m, uint(k))              //nolint:gosec
+	l := removeFactor(m, fiveInt)
+	nonRepeating := max(k, l)
+
+	if maxDigits > 0 && nonRepeating > maxDigits {
+		errE := errors.WithDetails(ErrRatPrecisionExceeded, "maxDigits", maxDigits)
+		errors.Details(errE)["nonRepeating"] = nonRepeating
+		return 0, 0, errE
+	}
+
+	if m.Cmp(oneInt) == 0 {
+		return nonRepeating, 0, nil
+	}
+
+	// The number of repeating digits is smaller than m.
+	if (maxDigits > 0 && maxDigits <= ratPrecisionMaxDigitsLoopLimit) || m.Cmp(big.NewInt(ratPrecisionLoopLimit)) <= 0 {
+		limit := ratPrecisionLoopLimit
+		if maxDigits > 0 {
+			limit = maxDigits
+		}
+		q := big.NewInt(1)
+		for j := 1; j <= limit; j++ {
+			if j%ratPrecisionLoopLimit == 0 && ctx.Err() != nil {
+				return 0, 0, errors.WithStack(ctx.Err())
+			}
+			q.Mul(q, tenInt)
+			q.Mod(q, m)
+			if q.Cmp(oneInt) == 0 {
+				return nonRepeating, j, nil
+			}
+		}
+		errE := errors.WithDetails(ErrRatPrecisionExceeded, "maxDigits", maxDigits)
+		errors.Details(errE)["nonRepeating"] = nonRepeating
+		return 0, 0, errE
+	}
+
+	if m.BitLen() > ratFactorizationBitsLimit {
+		errE := errors.WithDetails(ErrRatPrecisionExceeded, "maxDigits", maxDigits)
+		errors.Details(errE)["nonRepeating"] = nonRepeating
+		errors.Details(errE)["denominatorBits"] = m.BitLen()
+		return 0, 0, errE
+	}
+
+	steps := ratFactorizationStepsLimit
+	order, err := multiplicativeOrder(ctx, tenInt, m, &steps)
+	if errors.Is(err, errFactorizationLimit) {
+		errE := errors.WithDetails(ErrRatPrecisionExceeded, "maxDigits", maxDigits)
+		errors.Details(errE)["nonRepeating"] = nonRepeating
+		errors.Details(errE)["factorizationSteps"] = ratFactorizationStepsLimit
+		return 0, 0, errE
+	} else if err != nil {
+		return 0, 0, errors.WithStack(err)
+	}
+	if !order.IsInt64() || order.Int64() > int64(math.MaxInt) || (maxDigits > 0 && order.Int64() > int64(maxDigits)) {
+		errE := errors.WithDetails(ErrRatPrecisionExceeded, "maxDigits", maxDigits)
+		errors.Details(errE)["nonRepeating"] = nonRepeating
+		errors.Details(errE)["repeating"] = order.String()
+		return 0, 0, errE
+	}
+
+	return nonRepeating, int(order.Int64()), nil
+}
+
+// removeFactor divides m by the largest power of f which divides m
+// and returns the exponent. It divides by f, f^2, f^4, ... so it needs
+// only a logarithmic number of divisions.
+func removeFactor(m, f *big.Int) int {
 	q := new(big.Int)
 	r := new(big.Int)
 
-	k := 0
+	n := 0
+	powers := []*big.Int{f}
 	for {
-		q.QuoRem(m, twoInt, r)
-		if r.Cmp(zeroInt) == 0 {
-			m, q = q, m
-			k++
-		} else {
+		p := powers[len(powers)-1]
+		q.QuoRem(m, p, r)
+		if r.Sign() != 0 {
 			break
 		}
+		m.Set(q)
+		n += 1 << (len(powers) - 1)
+		next := new(big.Int).Mul(p, p)
+		if next.CmpAbs(m) > 0 {
+			break
+		}
+		powers = append(powers, next)
 	}
+	// The remaining exponent is smaller than 2^len(powers).
+	for i := len(powers) - 1; i >= 0; i-- {
+		q.QuoRem(m, powers[i], r)
+		if r.Sign() == 0 {
+			m.Set(q)
+			n += 1 << i
+		}
+	}
+	return n
+}
 
-	l := 0
-	for {
-		q.QuoRem(m, fiveInt, r)
-		if r.Cmp(zeroInt) == 0 {
-			m, q = q, m
-			l++
-		} else {
+// multiplicativeOrder returns the smallest t > 0 such that a^t = 1 (mod m).
+// a and m must be coprime and m must be larger than 1. Factorization
+// needed for it does at most *steps steps (see factorize).
+func multiplicativeOrder(ctx context.Context, a, m *big.Int, steps *int) (*big.Int, error) {
+	factors, err := factorize(ctx, m, steps)
+	if err != nil {
+		return nil, err
+	}
+
+	// Carmichael's function of m is a multiple of the order.
+	lambda := big.NewInt(1)
+	// Prime factors of lambda.
+	primes := map[string]*big.Int{}
+	for _, factor := range factors {
+		p1 := new(big.Int).Sub(factor.p, oneInt)
+		// For odd primes, λ(p^e) = (p-1)p^(e-1).
+		l := new(big.Int).Exp(factor.p, big.NewInt(int64(factor.e-1)), nil)
+		l.Mul(l, p1)
+		if factor.p.Cmp(twoInt) == 0 && factor.e > 2 { //nolint:mnd
+			// λ(2^e) = 2^(e-2) for e > 2.
+			l.Rsh(l, 1)
+		}
+		g := new(big.Int).GCD(nil, nil, lambda, l)
+		lambda.Mul(lambda, l.Div(l, g))
+
+		if factor.e > 1 {
+			primes[factor.p.String()] = factor.p
+		}
+		if p1.Cmp(oneInt) > 0 {
+			p1Factors, err := factorize(ctx, p1, steps)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range p1Factors {
+				primes[f.p.String()] = f.p
+			}
+		}
+	}
+
+	order := lambda
+	t := new(big.Int)
+	r := new(big.Int)
+	y := new(big.Int)
+	for _, q := range primes {
+		for {
+			err := ctx.Err()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			t.QuoRem(order, q, r)
+			if r.Sign() != 0 {
+				break
+			}
+			if y.Exp(a, t, m).Cmp(oneInt) != 0 {
+				break
+			}
+			order.Set(t)
+		}
+	}
+	return order, nil
+}
+
+// primeFactor is a prime factor p with exponent e.
+type primeFactor struct {
+	p *big.Int
+	e int
+}
+
+// factorize returns prime factors of n > 1, sorted by the prime.
+//
+// Steps of Pollard's rho algorithm are subtracted from *steps and
+// errFactorizationLimit is returned when there are no steps left.
+func factorize(ctx context.Context, n *big.Int, steps *int) ([]primeFactor, error) {
+	n = new(big.Int).Set(n)
+	primes := []*big.Int{}
+
+	q := new(big.Int)
+	r := new(big.Int)
+	d := new(big.Int)
+	for i := int64(2); i < ratTrialDivisionLimit && n.Cmp(oneInt) > 0; i++ {
+		d.SetInt64(i * i)
+		if d.Cmp(n) > 0 {
+			// n is a prime.
 			break
 		}
+		d.SetInt64(i)
+		for {
+			q.QuoRem(n, d, r)
+			if r.Sign() != 0 {
+				break
+			}
+			n.Set(q)
+			primes = append(primes, big.NewInt(i))
+		}
+	}
+
+	stack := []*big.Int{n}
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if v.Cmp(oneInt) == 0 {
+			continue
+		}
+		// Baillie-PSW test is exact for 64-bit numbers and there are no known counterexamples.
+		if v.ProbablyPrime(0) {
+			primes = append(primes, v)
+			continue
+		}
+		f, err := pollardRho(ctx, v, steps)
+		if err != nil {
+			return nil, err
+		}
+		stack = append(stack, f, new(big.Int).Quo(v, f))
+	}
+
+	slices.SortFunc(primes, func(a, b *big.Int) int {
+		return a.Cmp(b)
+	})
+	factors := []primeFactor{}
+	for _, p := range primes {
+		if len(factors) > 0 && factors[len(factors)-1].p.Cmp(p) == 0 {
+			factors[len(factors)-1].e++
+		} else {
+			factors = append(factors, primeFactor{p: p, e: 1})
+		}
+	}
+	return factors, nil
+}
+
+// pollardRho returns a non-trivial factor of composite n using Pollard's rho algorithm
+// with Floyd's cycle detection and batched GCD computations. n must not have small factors.
+// Steps done are subtracted from *steps.
+func pollardRho(ctx context.Context, n *big.Int, steps *int) (*big.Int, error) {
+	const batch = 128
+
+	x := new(big.Int)
+	y := new(big.Int)
+	xs := new(big.Int)
+	ys := new(big.Int)
+	c := new(big.Int)
+	q := new(big.Int)
+	d := new(big.Int)
+	diff := new(big.Int)
+
+	f := func(v *big.Int) {
+		v.Mul(v, v)
+		v.Add(v, c)
+		v.Mod(v, n)
+	}
+	step := func() {
+		f(x)
+		f(y)
+		f(y)
+		diff.Sub(x, y)
+		diff.Abs(diff)
 	}
 
-	j := 0
-	if m.Cmp(oneInt) != 0 {
+	for i := int64(1); ; i++ {
+		c.SetInt64(i)
+		x.SetInt64(2) //nolint:mnd
+		y.SetInt64(2) //nolint:mnd
 		q.SetInt64(1)
 		for {
-			q.Mul(q, tenInt)
-			q.Mod(q, m)
-			j++
-			if q.Cmp(oneInt) == 0 {
+			err := ctx.Err()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if *steps < batch {
+				return nil, errors.WithStack(errFactorizationLimit)
+			}
+			*steps -= batch
+			xs.Set(x)
+			ys.Set(y)
+			for range batch {
+				step()
+				q.Mul(q, diff)
+				q.Mod(q, n)
+			}
+			d.GCD(nil, nil, q, n)
+			if d.Cmp(oneInt) != 0 {
 				break
 			}
 		}
+		if d.Cmp(n) == 0 {
+			// The batch overshot, so we repeat it one step at a time.
+			x.Set(xs)
+			y.Set(ys)
+			for {
+				step()
+				d.GCD(nil, nil, diff, n)
+				if d.Cmp(oneInt) != 0 {
+					break
+				}
+			}
+		}
+		if d.Cmp(n) != 0 {
+			return d, nil
+		}
+		// Cycle without finding a factor, we try another c.
 	}
+}
+
+// ApproximateRat returns the closest rational number to x with the denominator
+// at most maxDenominator, e.g., 3/4 for 0.7501 and maxDenominator 10.
+//
+// It uses continued fractions to find the best rational approximation.
+// It panics if maxDenominator is smaller than 1.
+func ApproximateRat(x *big.Rat, maxDenominator int64) *big.Rat {
+	if maxDenominator < 1 {
+		panic(errors.New("maxDenominator must be at least 1"))
+	}
+
+	maxDen := big.NewInt(maxDenominator)
+	if x.Denom().Cmp(maxDen) <= 0 {
+		return new(big.Rat).Set(x)
+	}
+
+	// Convergents p0/q0 and p1/q1.
+	p0, q0, p1, q1 := big.NewInt(0), big.NewInt(1), big.NewInt(1), big.NewInt(0)
+	n := new(big.Int).Set(x.Num())
+	d := new(big.Int).Set(x.Denom())
+	a := new(big.Int)
+	q2 := new(big.Int)
+	for {
+		// Euclidean division, so a is the floor for positive d.
+		a.Div(n, d)
+		q2.Mul(a, q1)
+		q2.Add(q2, q0)
+		if q2.Cmp(maxDen) > 0 {
+			break
+		}
+		p2 := new(big.Int).Mul(a, p1)
+		p2.Add(p2, p0)
+		p0, q0, p1, q1 = p1, q1, p2, new(big.Int).Set(q2)
+		n, d = d, n.Sub(n, a.Mul(a, d))
+	}
+
+	// The best approximation is either the last convergent or
+	// the largest semiconvergent with an allowed denominator.
+	k := new(big.Int).Sub(maxDen, q0)
+	k.Div(k, q1)
+	bound1 := new(big.Rat).SetFrac(
+		new(big.Int).Add(p0, new(big.Int).Mul(k, p1)),
+		new(big.Int).Add(q0, new(big.Int).Mul(k, q1)),
+	)
+	bound2 := new(big.Rat).SetFrac(p1, q1)
 
-	if k > l {
-		return k, j
+	diff1 := new(big.Rat).Sub(bound1, x)
+	diff2 := new(big.Rat).Sub(bound2, x)
+	if diff2.Abs(diff2).Cmp(diff1.Abs(diff1)) <= 0 {
+		return bound2
 	}
-	return l, j
+	return bound1
+}
+
+// RatFromFloat64Shortest returns the rational number with the shortest decimal
+// representation which converts back to f, e.g., 1/10 for 0.1 (while Rat.SetFloat64
+// returns 3602879701896397/36028797018963968, the exact value of f).
+// It returns nil if f is not finite.
+func RatFromFloat64Shortest(f float64) *big.Rat {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil
+	}
+	rat, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'e', -1, 64))
+	return rat
+}
+
+// RatNotation is a notation for repeating digits used by FormatRatWithOptions.
+type RatNotation int
+
+const (
+	// RatNotationParentheses encloses repeating digits in parentheses, e.g., "0.1(6)".
+	RatNotationParentheses RatNotation = iota
+	// RatNotationEllipsis repeats repeating digits at least twice and until there are
+	// at least three of them, followed by an ellipsis, e.g., "0.1666…" for 1/6 or "0.0909…" for 1/11.
+	// This notation cannot be parsed with ParseRat.
+	RatNotationEllipsis
+)
+
+// minEllipsisRepeats and minEllipsisDigits control how many times repeating
+// digits are repeated in RatNotationEllipsis.
+const (
+	minEllipsisRepeats = 2
+	minEllipsisDigits  = 3
+)
+
+// RatFormatOptions are options for FormatRatWithOptions.
+type RatFormatOptions struct {
+	// Notation for repeating digits.
+	Notation RatNotation
+}
+
+// FormatRat formats rat as an exact decimal number, enclosing repeating
+// digits in parentheses, e.g., "0.1(6)" for 1/6.
+//
+// It needs time and memory proportional to the number of repeating digits, which is
+// not bounded. It panics if the number of repeating digits does not fit into int or
+// cannot be determined within the bound RatPrecision has. Use FormatRatContext for untrusted inputs.
+//
+// See FormatRatWithOptions for details.
+func FormatRat(rat *big.Rat) string {
+	return FormatRatWithOptions(rat, RatFormatOptions{
+		Notation: RatNotationParentheses,
+	})
+}
+
+// FormatRatWithOptions formats rat as an exact decimal number: the integer part,
+// and (if rat is not an integer) the decimal point followed by non-repeating
+// digits and repeating digits, as determined by RatPrecision, in the configured notation.
+//
+// The number of repeating digits can be as large as the denominator, e.g., 1/p for
+// a large prime p has p-1 repeating digits, and all of them are generated. FormatRatWithOptions
+// panics if the number of repeating digits does not fit into int or cannot be determined within
+// the bound RatPrecision has. Use FormatRatContext for untrusted inputs.
+func FormatRatWithOptions(rat *big.Rat, options RatFormatOptions) string {
+	s, errE := FormatRatContext(context.Background(), rat, 0, options)
+	if errE != nil {
+		panic(errE)
+	}
+	return s
+}
+
+// FormatRatContext is like FormatRatWithOptions, but it returns an error if ctx is canceled
+// or if the number of non-repeating or repeating digits is larger than maxDigits
+// (see RatPrecisionContext). If maxDigits is 0, there is no limit on the number of digits.
+func FormatRatContext(ctx context.Context, rat *big.Rat, maxDigits int, options RatFormatOptions) (string, errors.E) {
+	nonRepeating, repeating, errE := RatPrecisionContext(ctx, rat, maxDigits)
+	if errE != nil {
+		return "", errE
+	}
+
+	var b strings.Builder
+	if rat.Sign() < 0 {
+		b.WriteString("-")
+	}
+
+	q, r := new(big.Int).QuoRem(new(big.Int).Abs(rat.Num()), rat.Denom(), new(big.Int))
+	b.WriteString(q.String())
+
+	if nonRepeating+repeating == 0 {
+		return b.String(), nil
+	}
+
+	// Long division.
+	digits := make([]byte, nonRepeating+repeating)
+	for i := range digits {
+		if i%ratPrecisionLoopLimit == 0 && ctx.Err() != nil {
+			return "", errors.WithStack(ctx.Err())
+		}
+		r.Mul(r, tenInt)
+		q.QuoRem(r, rat.Denom(), r)
+		digits[i] = '0' + byte(q.Int64())
+	}
+
+	b.WriteString(".")
+	b.Write(digits[:nonRepeating])
+	if repeating == 0 {
+		return b.String(), nil
+	}
+
+	if options.Notation == RatNotationEllipsis {
+		for i := 0; i < minEllipsisRepeats || i*repeating < minEllipsisDigits; i++ {
+			b.Write(digits[nonRepeating:])
+		}
+		b.WriteString("…")
+	} else {
+		b.WriteString("(")
+		b.Write(digits[nonRepeating:])
+		b.WriteString(")")
+	}
+	return b.String(), nil
+}
+
+// ratRegexp matches a decimal number with optional repeating digits in parentheses.
+//
+//nolint:gochecknoglobals
+var ratRegexp = regexp.MustCompile(`^([+-]?)([0-9]+)(?:\.([0-9]*)(?:\(([0-9]+)\))?)?$`)
+
+// ParseRat parses s as an exact decimal number, with optional repeating
+// digits enclosed in parentheses, e.g., "0.1(6)" or "-12.(3)". It is the
+// inverse of FormatRat.
+func ParseRat(s string) (*big.Rat, errors.E) {
+	match := ratRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return nil, errors.WithDetails(ErrInvalidRat, "value", s)
+	}
+	sign, integer, nonRepeating, repeating := match[1], match[2], match[3], match[4]
+
+	n, _ := new(big.Int).SetString(integer+nonRepeating, 10) //nolint:mnd
+	d := new(big.Int).Exp(tenInt, big.NewInt(int64(len(nonRepeating))), nil)
+	result := new(big.Rat).SetFrac(n, d)
+
+	if repeating != "" {
+		// Repeating digits R following k non-repeating digits contribute R / (10^k * (10^j - 1)).
+		n, _ := new(big.Int).SetString(repeating, 10) //nolint:mnd
+		nines := new(big.Int).Exp(tenInt, big.NewInt(int64(len(repeating))), nil)
+		nines.Sub(nines, oneInt)
+		result.Add(result, new(big.Rat).SetFrac(n, d.Mul(d, nines)))
+	}
+
+	if sign == "-" {
+		result.Neg(result)
+	}
+	return result, nil
+}
+
+// RoundingMode determines how RoundRat rounds a number which cannot be represented
+// exactly with the requested number of decimal places.
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest, ties to the even neighbor (banker's rounding).
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest, ties away from zero. This is what Rat.FloatString does.
+	RoundHalfUp
+	// RoundHalfDown rounds to the nearest, ties toward zero.
+	RoundHalfDown
+	// RoundCeiling rounds toward positive infinity.
+	RoundCeiling
+	// RoundFloor rounds toward negative infinity.
+	RoundFloor
+	// RoundTowardZero rounds toward zero (truncation).
+	RoundTowardZero
+	// RoundAwayFromZero rounds away from zero.
+	RoundAwayFromZero
+)
+
+// RoundRat rounds rat to places decimal places using mode. If places is negative,
+// rat is rounded to a multiple of 10^-places, e.g., to tens for -1.
+//
+// It returns also true if rounding was not necessary. For non-negative places
+// this means that rat has at most places non-repeating digits and no repeating
+// digits (see RatPrecision).
+func RoundRat(rat *big.Rat, places int, mode RoundingMode) (*big.Rat, bool) {
+	scale := new(big.Int).Exp(tenInt, big.NewInt(int64(max(places, -places))), nil)
+
+	n := new(big.Int).Abs(rat.Num())
+	d := new(big.Int).Set(rat.Denom())
+	if places >= 0 {
+		n.Mul(n, scale)
+	} else {
+		d.Mul(d, scale)
+	}
+
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	exact := r.Sign() == 0
+	if !exact && roundUp(q, r, d, rat.Sign() < 0, mode) {
+		q.Add(q, oneInt)
+	}
+	if rat.Sign() < 0 {
+		q.Neg(q)
+	}
+
+	result := new(big.Rat)
+	if places >= 0 {
+		result.SetFrac(q, scale)
+	} else {
+		result.SetInt(q.Mul(q, scale))
+	}
+	return result, exact
+}
+
+// roundUp returns true if the magnitude q of the truncated result should be
+// increased by one, given remainder r > 0 of the division by d.
+func roundUp(q, r, d *big.Int, negative bool, mode RoundingMode) bool {
+	switch mode {
+	case RoundTowardZero:
+		return false
+	case RoundAwayFromZero:
+		return true
+	case RoundCeiling:
+		return !negative
+	case RoundFloor:
+		return negative
+	case RoundHalfEven, RoundHalfUp, RoundHalfDown:
+	}
+
+	c := new(big.Int).Lsh(r, 1).Cmp(d)
+	if c != 0 {
+		return c > 0
+	}
+	// A tie.
+	switch mode { //nolint:exhaustive
+	case RoundHalfUp:
+		return true
+	case RoundHalfDown:
+		return false
+	default:
+		return q.Bit(0) == 1
+	}
+}
+
+// FormatRatFixed formats rat with exactly places decimal places, rounding it
+// using mode. If places is negative, rat is rounded to a multiple of 10^-places
+// and formatted without a decimal point.
+//
+// It returns also true if rounding was not necessary.
+func FormatRatFixed(rat *big.Rat, places int, mode RoundingMode) (string, bool) {
+	rounded, exact := RoundRat(rat, places, mode)
+	return rounded.FloatString(max(places, 0)), exact
 }
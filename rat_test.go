@@ -1,13 +1,17 @@
 package x_test
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"math/big"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gitlab.com/tozd/go/errors"
 
 	"gitlab.com/tozd/go/x"
 )
@@ -144,6 +148,189 @@ func TestRatPrecisionString(t *testing.T) {
 	}
 }
 
+// ratPrecisionNaive is the original implementation of RatPrecision which finds
+// the number of repeating digits by repeatedly multiplying by 10 modulo the denominator.
+func ratPrecisionNaive(rat *big.Rat) (int, int) {
+	zeroInt := big.NewInt(0)
+	oneInt := big.NewInt(1)
+	twoInt := big.NewInt(2)
+	fiveInt := big.NewInt(5)
+	tenInt := big.NewInt(10)
+
+	m := new(big.Int).Set(rat.Denom())
+
+	q := new(big.Int)
+	r := new(big.Int)
+
+	k := 0
+	for {
+		q.QuoRem(m, twoInt, r)
+		if r.Cmp(zeroInt) == 0 {
+			m, q = q, m
+			k++
+		} else {
+			break
+		}
+	}
+
+	l := 0
+	for {
+		q.QuoRem(m, fiveInt, r)
+		if r.Cmp(zeroInt) == 0 {
+			m, q = q, m
+			l++
+		} else {
+			break
+		}
+	}
+
+	j := 0
+	if m.Cmp(oneInt) != 0 {
+		q.SetInt64(1)
+		for {
+			q.Mul(q, tenInt)
+			q.Mod(q, m)
+			j++
+			if q.Cmp(oneInt) == 0 {
+				break
+			}
+		}
+	}
+
+	return max(k, l), j
+}
+
+func TestRatPrecisionNaive(t *testing.T) {
+	t.Parallel()
+
+	for d := int64(1); d <= 2000; d++ {
+		r := big.NewRat(1, d)
+		k, j := x.RatPrecision(r)
+		expectedK, expectedJ := ratPrecisionNaive(r)
+		assert.Equal(t, expectedK, k, d)
+		assert.Equal(t, expectedJ, j, d)
+	}
+
+	for _, d := range []int64{1 << 40, 5 * 5 * 5 * 5 * 7 * 7 * 7 * 11 * 13, 99991, 3 * 9973 * 9967, 2 * 1009 * 1009} {
+		r := big.NewRat(3, d)
+		k, j := x.RatPrecision(r)
+		expectedK, expectedJ := ratPrecisionNaive(r)
+		assert.Equal(t, expectedK, k, d)
+		assert.Equal(t, expectedJ, j, d)
+	}
+}
+
+func TestRatPrecisionLarge(t *testing.T) {
+	t.Parallel()
+
+	// 2^61-1 is a Mersenne prime.
+	p := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 61), big.NewInt(1))
+	d := new(big.Int).Mul(p, big.NewInt(1000))
+
+	k, j := x.RatPrecision(new(big.Rat).SetFrac(big.NewInt(1), d))
+	assert.Equal(t, 3, k)
+
+	// j is the multiplicative order of 10 modulo p.
+	order := big.NewInt(int64(j))
+	one := big.NewInt(1)
+	assert.Equal(t, 0, new(big.Int).Exp(big.NewInt(10), order, p).Cmp(one))
+	for q := int64(2); q <= 1000; q++ {
+		if j%int(q) == 0 {
+			assert.NotEqual(t, 0, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(j)/q), p).Cmp(one), q)
+		}
+	}
+
+	// The number of repeating digits for 2^127-1 does not fit into int.
+	p = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+	_, _, errE := x.RatPrecisionContext(context.Background(), new(big.Rat).SetFrac(big.NewInt(1), p), 0)
+	assert.ErrorIs(t, errE, x.ErrRatPrecisionExceeded)
+
+	k, j = x.RatPrecision(new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Mul(p, big.NewInt(8))))
+	assert.Equal(t, 3, k)
+	assert.Equal(t, -1, j)
+}
+
+func TestRatPrecisionContext(t *testing.T) {
+	t.Parallel()
+
+	// Product of Mersenne primes 2^89-1 and 2^127-1 cannot be factorized quickly.
+	p1 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 89), big.NewInt(1))
+	p2 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+	hard := new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Mul(p1, p2))
+
+	t.Run("max digits", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, errE := x.RatPrecisionContext(context.Background(), hard, 1000)
+		assert.ErrorIs(t, errE, x.ErrRatPrecisionExceeded)
+
+		_, _, errE = x.RatPrecisionContext(context.Background(), big.NewRat(1, 1<<20), 10)
+		assert.ErrorIs(t, errE, x.ErrRatPrecisionExceeded)
+
+		_, _, errE = x.RatPrecisionContext(context.Background(), big.NewRat(1, 7), 5)
+		assert.ErrorIs(t, errE, x.ErrRatPrecisionExceeded)
+
+		k, j, errE := x.RatPrecisionContext(context.Background(), big.NewRat(1, 7), 6)
+		require.NoError(t, errE, "% -+#.1v", errE)
+		assert.Equal(t, 0, k)
+		assert.Equal(t, 6, j)
+
+		k, j, errE = x.RatPrecisionContext(context.Background(), big.NewRat(1, 304000), 100_000)
+		require.NoError(t, errE, "% -+#.1v", errE)
+		assert.Equal(t, 7, k)
+		assert.Equal(t, 18, j)
+
+		_, j, errE = x.RatPrecisionContext(context.Background(), big.NewRat(1, 9967), 100_000)
+		require.NoError(t, errE, "% -+#.1v", errE)
+		assert.Equal(t, 9966, j)
+		_, _, errE = x.RatPrecisionContext(context.Background(), big.NewRat(1, 9967), 9965)
+		assert.ErrorIs(t, errE, x.ErrRatPrecisionExceeded)
+
+		// Larger maxDigits use factorization.
+		_, j, errE = x.RatPrecisionContext(context.Background(), big.NewRat(1, 9967), 1<<21)
+		require.NoError(t, errE, "% -+#.1v", errE)
+		assert.Equal(t, 9966, j)
+
+		// maxDigits bounds the work also for denominators which cannot be factorized quickly.
+		_, _, errE = x.RatPrecisionContext(context.Background(), hard, 100_000)
+		assert.ErrorIs(t, errE, x.ErrRatPrecisionExceeded)
+	})
+
+	t.Run("factorization limit", func(t *testing.T) {
+		t.Parallel()
+
+		// Without maxDigits, the work factorization does is bounded as well.
+		_, _, errE := x.RatPrecisionContext(context.Background(), hard, 0)
+		assert.ErrorIs(t, errE, x.ErrRatPrecisionExceeded)
+		assert.Equal(t, 1<<16, errors.Details(errE)["factorizationSteps"])
+
+		k, j := x.RatPrecision(new(big.Rat).Mul(hard, big.NewRat(1, 100)))
+		assert.Equal(t, 2, k)
+		assert.Equal(t, -1, j)
+
+		// Large denominators are not factorized at all.
+		large := new(big.Int).Lsh(big.NewInt(1), 600)
+		large.Add(large, big.NewInt(1))
+		_, _, errE = x.RatPrecisionContext(context.Background(), new(big.Rat).SetFrac(big.NewInt(1), large), 0)
+		assert.ErrorIs(t, errE, x.ErrRatPrecisionExceeded)
+		assert.Equal(t, 601, errors.Details(errE)["denominatorBits"])
+
+		assert.Panics(t, func() {
+			x.FormatRat(hard)
+		})
+	})
+
+	t.Run("context", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, _, errE := x.RatPrecisionContext(ctx, hard, 0)
+		assert.ErrorIs(t, errE, context.Canceled)
+	})
+}
+
 func BenchmarkRatPrecision(b *testing.B) {
 	r := big.NewRat(1, 67)
 	b.ResetTimer()
@@ -152,6 +339,24 @@ func BenchmarkRatPrecision(b *testing.B) {
 	}
 }
 
+func BenchmarkRatPrecisionLargeDenominator(b *testing.B) {
+	for _, d := range []int64{67, 9973, 999983, 99999989} {
+		r := big.NewRat(1, d)
+
+		b.Run(fmt.Sprintf("order/%d", d), func(b *testing.B) {
+			for range b.N {
+				x.RatPrecision(r)
+			}
+		})
+
+		b.Run(fmt.Sprintf("naive/%d", d), func(b *testing.B) {
+			for range b.N {
+				ratPrecisionNaive(r)
+			}
+		})
+	}
+}
+
 // Benchmark taken from tests for Rat.FloatPrec.
 //
 //nolint:godot
@@ -202,3 +407,357 @@ func BenchmarkFloatPrecInexact(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkRatPrecisionContextInexact(b *testing.B) {
+	for _, n := range []int{1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6} {
+		// d := 5^n + 1
+		d := big.NewInt(5)
+		p := big.NewInt(int64(n))
+		d.Exp(d, p, nil)
+		d.Add(d, big.NewInt(1))
+
+		// r := 1/d
+		var r big.Rat
+		r.SetFrac(big.NewInt(1), d)
+
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			for range b.N {
+				// For larger n, the number of repeating digits exceeds maxDigits,
+				// so we expect an error for them.
+				_, rep, errE := x.RatPrecisionContext(context.Background(), &r, 10_000)
+				if errE != nil {
+					if !errors.Is(errE, x.ErrRatPrecisionExceeded) {
+						b.Fatalf("got unexpected error: %v", errE)
+					}
+				} else if rep == 0 {
+					b.Fatalf("got unexpected zero rep")
+				}
+			}
+		})
+	}
+}
+
+func TestFormatRat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rat         string
+		parentheses string
+		ellipsis    string
+	}{
+		{"0", "0", "0"},
+		{"5", "5", "5"},
+		{"-5", "-5", "-5"},
+		{"1/2", "0.5", "0.5"},
+		{"-1/2", "-0.5", "-0.5"},
+		{"1/3", "0.(3)", "0.333…"},
+		{"2/3", "0.(6)", "0.666…"},
+		{"1/6", "0.1(6)", "0.1666…"},
+		{"-1/6", "-0.1(6)", "-0.1666…"},
+		{"1/7", "0.(142857)", "0.142857142857…"},
+		{"1/11", "0.(09)", "0.0909…"},
+		{"1/12", "0.08(3)", "0.08333…"},
+		{"10/3", "3.(3)", "3.333…"},
+		{"22/7", "3.(142857)", "3.142857142857…"},
+		{"-22/7", "-3.(142857)", "-3.142857142857…"},
+		{"1/304000", "0.0000032(894736842105263157)", "0.0000032894736842105263157894736842105263157…"},
+		{"123456789/1000", "123456.789", "123456.789"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rat, func(t *testing.T) {
+			t.Parallel()
+
+			r, ok := new(big.Rat).SetString(tt.rat)
+			require.True(t, ok)
+
+			assert.Equal(t, tt.parentheses, x.FormatRat(r))
+			assert.Equal(t, tt.ellipsis, x.FormatRatWithOptions(r, x.RatFormatOptions{Notation: x.RatNotationEllipsis}))
+
+			parsed, errE := x.ParseRat(tt.parentheses)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, r.String(), parsed.String())
+		})
+	}
+}
+
+func TestFormatRatContext(t *testing.T) {
+	t.Parallel()
+
+	s, errE := x.FormatRatContext(context.Background(), big.NewRat(1, 7), 6, x.RatFormatOptions{Notation: x.RatNotationParentheses})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "0.(142857)", s)
+
+	s, errE = x.FormatRatContext(context.Background(), big.NewRat(-1, 6), 0, x.RatFormatOptions{Notation: x.RatNotationEllipsis})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "-0.1666…", s)
+
+	_, errE = x.FormatRatContext(context.Background(), big.NewRat(1, 7), 5, x.RatFormatOptions{Notation: x.RatNotationParentheses})
+	assert.ErrorIs(t, errE, x.ErrRatPrecisionExceeded)
+
+	// The number of repeating digits of 1/(10^20+39) does not fit into int.
+	p, _ := new(big.Int).SetString("100000000000000000039", 10)
+	_, errE = x.FormatRatContext(context.Background(), new(big.Rat).SetFrac(big.NewInt(1), p), 1000, x.RatFormatOptions{Notation: x.RatNotationParentheses})
+	assert.ErrorIs(t, errE, x.ErrRatPrecisionExceeded)
+
+	// 999983 is a prime, so 1/p has many repeating digits.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, errE = x.FormatRatContext(ctx, big.NewRat(1, 999983), 0, x.RatFormatOptions{Notation: x.RatNotationParentheses})
+	assert.ErrorIs(t, errE, context.Canceled)
+
+	assert.Panics(t, func() {
+		x.FormatRat(new(big.Rat).SetFrac(big.NewInt(1), p))
+	})
+}
+
+func TestParseRat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		s   string
+		rat string
+	}{
+		{"0", "0/1"},
+		{"-0", "0/1"},
+		{"+1.5", "3/2"},
+		{"1.", "1/1"},
+		{"0.(9)", "1/1"},
+		{"0.4(9)", "1/2"},
+		{"0.(3)", "1/3"},
+		{"0.3(33)", "1/3"},
+		{"0.(142857142857)", "1/7"},
+		{"12.34(56)", "61111/4950"},
+		{"-0.1(6)", "-1/6"},
+		{"007.10", "71/10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			t.Parallel()
+
+			r, errE := x.ParseRat(tt.s)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, tt.rat, r.String())
+		})
+	}
+
+	for _, s := range []string{"", "-", ".5", "1.2.3", "0.()", "0.(3", "0.3)", "1(3)", "0.1666…", "1/3", "1e3", " 1", "0x10"} {
+		t.Run(s, func(t *testing.T) {
+			t.Parallel()
+
+			_, errE := x.ParseRat(s)
+			assert.ErrorIs(t, errE, x.ErrInvalidRat)
+		})
+	}
+}
+
+func TestFormatRatRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for n := int64(-50); n <= 50; n++ {
+		for d := int64(1); d <= 120; d++ {
+			r := big.NewRat(n, d)
+			s := x.FormatRat(r)
+			parsed, errE := x.ParseRat(s)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, r.String(), parsed.String(), s)
+		}
+	}
+}
+
+func TestRoundRat(t *testing.T) {
+	t.Parallel()
+
+	// The table from Java's RoundingMode documentation.
+	values := []string{"5.5", "2.5", "1.6", "1.1", "1.0", "-1.0", "-1.1", "-1.6", "-2.5", "-5.5"}
+	tests := []struct {
+		mode     x.RoundingMode
+		expected []string
+	}{
+		{x.RoundAwayFromZero, []string{"6", "3", "2", "2", "1", "-1", "-2", "-2", "-3", "-6"}},
+		{x.RoundTowardZero, []string{"5", "2", "1", "1", "1", "-1", "-1", "-1", "-2", "-5"}},
+		{x.RoundCeiling, []string{"6", "3", "2", "2", "1", "-1", "-1", "-1", "-2", "-5"}},
+		{x.RoundFloor, []string{"5", "2", "1", "1", "1", "-1", "-2", "-2", "-3", "-6"}},
+		{x.RoundHalfUp, []string{"6", "3", "2", "1", "1", "-1", "-1", "-2", "-3", "-6"}},
+		{x.RoundHalfDown, []string{"5", "2", "2", "1", "1", "-1", "-1", "-2", "-2", "-5"}},
+		{x.RoundHalfEven, []string{"6", "2", "2", "1", "1", "-1", "-1", "-2", "-2", "-6"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(int(tt.mode)), func(t *testing.T) {
+			t.Parallel()
+
+			for i, value := range values {
+				r, ok := new(big.Rat).SetString(value)
+				require.True(t, ok)
+
+				rounded, exact := x.RoundRat(r, 0, tt.mode)
+				assert.Equal(t, tt.expected[i], rounded.RatString(), value)
+				assert.Equal(t, value == "1.0" || value == "-1.0", exact, value)
+
+				s, exact := x.FormatRatFixed(r, 0, tt.mode)
+				assert.Equal(t, tt.expected[i], s, value)
+				assert.Equal(t, value == "1.0" || value == "-1.0", exact, value)
+			}
+		})
+	}
+}
+
+func TestFormatRatFixed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rat      string
+		places   int
+		mode     x.RoundingMode
+		expected string
+		exact    bool
+	}{
+		{"2.675", 2, x.RoundHalfEven, "2.68", false},
+		{"2.665", 2, x.RoundHalfEven, "2.66", false},
+		{"-2.665", 2, x.RoundHalfEven, "-2.66", false},
+		{"2.665", 2, x.RoundHalfUp, "2.67", false},
+		{"2.665", 2, x.RoundHalfDown, "2.66", false},
+		{"2.5", 2, x.RoundHalfEven, "2.50", true},
+		{"1/3", 4, x.RoundHalfEven, "0.3333", false},
+		{"2/3", 4, x.RoundHalfEven, "0.6667", false},
+		{"2/3", 4, x.RoundFloor, "0.6666", false},
+		{"-2/3", 4, x.RoundFloor, "-0.6667", false},
+		{"-2/3", 4, x.RoundCeiling, "-0.6666", false},
+		{"1/8", 3, x.RoundHalfEven, "0.125", true},
+		{"1/8", 2, x.RoundHalfEven, "0.12", false},
+		{"3/8", 2, x.RoundHalfEven, "0.38", false},
+		{"-0.001", 2, x.RoundHalfEven, "0.00", false},
+		{"-0.001", 2, x.RoundAwayFromZero, "-0.01", false},
+		{"0", 2, x.RoundAwayFromZero, "0.00", true},
+		{"1250", -2, x.RoundHalfEven, "1200", false},
+		{"1350", -2, x.RoundHalfEven, "1400", false},
+		{"1350", -1, x.RoundHalfEven, "1350", true},
+		{"-1351", -2, x.RoundTowardZero, "-1300", false},
+		{"123456789.123456789", 5, x.RoundHalfUp, "123456789.12346", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d/%d", tt.rat, tt.places, tt.mode), func(t *testing.T) {
+			t.Parallel()
+
+			r, ok := new(big.Rat).SetString(tt.rat)
+			require.True(t, ok)
+
+			s, exact := x.FormatRatFixed(r, tt.places, tt.mode)
+			assert.Equal(t, tt.expected, s)
+			assert.Equal(t, tt.exact, exact)
+
+			// Exactness matches RatPrecision.
+			if tt.places >= 0 {
+				nonRepeating, repeating := x.RatPrecision(r)
+				assert.Equal(t, repeating == 0 && nonRepeating <= tt.places, exact)
+			}
+		})
+	}
+}
+
+func TestApproximateRat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		x              string
+		maxDenominator int64
+		expected       string
+	}{
+		{"3.141592653589793", 10, "22/7"},
+		{"3.141592653589793", 100, "311/99"},
+		{"3.141592653589793", 1000, "355/113"},
+		{"-3.141592653589793", 1000, "-355/113"},
+		{"4321/8765", 10000, "4321/8765"},
+		{"0.7501", 10, "3/4"},
+		{"0.7501", 1, "1/1"},
+		{"0.4", 1, "0/1"},
+		{"-0.7501", 10, "-3/4"},
+		{"0.333", 100, "1/3"},
+		{"0.3333", 2, "1/2"},
+		{"5", 1, "5/1"},
+		{"1.4142135623730951", 1000, "1393/985"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.x, tt.maxDenominator), func(t *testing.T) {
+			t.Parallel()
+
+			r, ok := new(big.Rat).SetString(tt.x)
+			require.True(t, ok)
+			assert.Equal(t, tt.expected, x.ApproximateRat(r, tt.maxDenominator).String())
+		})
+	}
+
+	assert.Panics(t, func() {
+		x.ApproximateRat(big.NewRat(1, 3), 0)
+	})
+}
+
+func TestApproximateRatBruteForce(t *testing.T) {
+	t.Parallel()
+
+	for n := int64(-40); n <= 40; n++ {
+		for d := int64(1); d <= 30; d++ {
+			r := big.NewRat(n, d)
+			for maxDenominator := int64(1); maxDenominator <= 12; maxDenominator++ {
+				approximation := x.ApproximateRat(r, maxDenominator)
+				require.LessOrEqual(t, approximation.Denom().Int64(), maxDenominator)
+
+				distance := new(big.Rat).Sub(approximation, r)
+				distance.Abs(distance)
+
+				// No fraction with an allowed denominator is closer.
+				for q := int64(1); q <= maxDenominator; q++ {
+					p := new(big.Int).Div(new(big.Int).Mul(r.Num(), big.NewInt(q)), r.Denom())
+					for _, candidate := range []*big.Int{p, new(big.Int).Add(p, big.NewInt(1))} {
+						c := new(big.Rat).SetFrac(candidate, big.NewInt(q))
+						cDistance := new(big.Rat).Sub(c, r)
+						cDistance.Abs(cDistance)
+						require.LessOrEqual(t, distance.Cmp(cDistance), 0, "%s %d %s", r, maxDenominator, c)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRatFromFloat64Shortest(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		f        float64
+		expected string
+	}{
+		{0, "0/1"},
+		{0.1, "1/10"},
+		{-0.1, "-1/10"},
+		{0.75, "3/4"},
+		{1.0 / 3, "3333333333333333/10000000000000000"},
+		{123456.789, "123456789/1000"},
+		{1e300, "1" + strings.Repeat("0", 300) + "/1"},
+		{5e-324, "1/2" + strings.Repeat("0", 323)},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.FormatFloat(tt.f, 'g', -1, 64), func(t *testing.T) {
+			t.Parallel()
+
+			r := x.RatFromFloat64Shortest(tt.f)
+			require.NotNil(t, r)
+			assert.Equal(t, tt.expected, r.String())
+
+			f, _ := r.Float64()
+			assert.Equal(t, tt.f, f) //nolint:testifylint
+		})
+	}
+
+	assert.Nil(t, x.RatFromFloat64Shortest(math.Inf(1)))
+	assert.Nil(t, x.RatFromFloat64Shortest(math.Inf(-1)))
+	assert.Nil(t, x.RatFromFloat64Shortest(math.NaN()))
+
+	// Measured ratios from noisy inputs.
+	assert.Equal(t, "3/4", x.ApproximateRat(x.RatFromFloat64Shortest(0.7499999), 10).String())
+}
@@ -0,0 +1,246 @@
+package x
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+var ErrScanSQLValue = errors.Base("cannot scan SQL value")
+
+// Scan implements [sql.Scanner] interface for Time.
+//
+// It accepts time.Time values, int64 and float64 values of seconds since
+// the Unix epoch, and string and []byte values as supported by UnmarshalText.
+// Use NullTime for nullable columns.
+func (t *Time) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*t = Time(v)
+		return nil
+	case int64:
+		*t = Time(time.Unix(v, 0))
+		return nil
+	case float64:
+		r := RatFromFloat64Shortest(v)
+		if r == nil {
+			return errors.WithDetails(ErrInvalidTimestamp, "time", v)
+		}
+		r, _ = RoundRat(r, 9, RoundHalfEven) //nolint:mnd
+		tt, errE := TimeFromRat(r)
+		if errE != nil {
+			return errE
+		}
+		*t = Time(tt)
+		return nil
+	case string:
+		return t.UnmarshalText([]byte(v))
+	case []byte:
+		return t.UnmarshalText(v)
+	}
+	return errors.WithDetails(ErrScanSQLValue, "type", fmt.Sprintf("%T", src))
+}
+
+// Value implements [driver.Valuer] interface for Time.
+//
+// It returns time.Time with full precision.
+func (t Time) Value() (driver.Value, error) {
+	return time.Time(t), nil
+}
+
+// Scan implements [sql.Scanner] interface for Duration.
+//
+// It accepts int64 values of nanoseconds (as time.Duration is stored by database/sql),
+// and string and []byte values as supported by ParseDuration.
+// Use NullDuration for nullable columns.
+func (d *Duration) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		*d = Duration(v)
+		return nil
+	case string:
+		return d.UnmarshalText([]byte(v))
+	case []byte:
+		return d.UnmarshalText(v)
+	}
+	return errors.WithDetails(ErrScanSQLValue, "type", fmt.Sprintf("%T", src))
+}
+
+// Value implements [driver.Valuer] interface for Duration.
+//
+// It returns int64 nanoseconds.
+func (d Duration) Value() (driver.Value, error) {
+	return int64(d), nil
+}
+
+// NullTime is a Time which may be absent. It is absent when Valid is false.
+//
+// It marshals to and unmarshals from JSON null, empty text, and SQL NULL
+// when absent, and otherwise the same as Time.
+type NullTime struct {
+	Time  Time
+	Valid bool
+}
+
+// MarshalJSON implements [json.Marshaler] interface for NullTime.
+func (t NullTime) MarshalJSON() ([]byte, error) {
+	if !t.Valid {
+		return []byte("null"), nil
+	}
+	return t.Time.MarshalJSON()
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for NullTime.
+func (t *NullTime) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*t = NullTime{Time: Time{}, Valid: false}
+		return nil
+	}
+	err := t.Time.UnmarshalJSON(data)
+	if err != nil {
+		return err
+	}
+	t.Valid = true
+	return nil
+}
+
+// MarshalText implements [encoding.TextMarshaler] interface for NullTime.
+func (t NullTime) MarshalText() ([]byte, error) {
+	if !t.Valid {
+		return []byte{}, nil
+	}
+	return t.Time.MarshalText()
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler] interface for NullTime.
+func (t *NullTime) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*t = NullTime{Time: Time{}, Valid: false}
+		return nil
+	}
+	err := t.Time.UnmarshalText(text)
+	if err != nil {
+		return err
+	}
+	t.Valid = true
+	return nil
+}
+
+// String implements [fmt.Stringer] interface for NullTime.
+//
+// It returns an empty string when absent.
+func (t NullTime) String() string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.String()
+}
+
+// Scan implements [sql.Scanner] interface for NullTime.
+func (t *NullTime) Scan(src any) error {
+	if src == nil {
+		*t = NullTime{Time: Time{}, Valid: false}
+		return nil
+	}
+	err := t.Time.Scan(src)
+	if err != nil {
+		return err
+	}
+	t.Valid = true
+	return nil
+}
+
+// Value implements [driver.Valuer] interface for NullTime.
+func (t NullTime) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil //nolint:nilnil
+	}
+	return t.Time.Value()
+}
+
+// NullDuration is a Duration which may be absent. It is absent when Valid is false.
+//
+// It marshals to and unmarshals from JSON null, empty text, and SQL NULL
+// when absent, and otherwise the same as Duration.
+type NullDuration struct {
+	Duration Duration
+	Valid    bool
+}
+
+// MarshalJSON implements [json.Marshaler] interface for NullDuration.
+func (d NullDuration) MarshalJSON() ([]byte, error) {
+	if !d.Valid {
+		return []byte("null"), nil
+	}
+	return d.Duration.MarshalJSON()
+}
+
+// UnmarshalJSON implements [json.Unmarshaler] interface for NullDuration.
+func (d *NullDuration) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = NullDuration{Duration: 0, Valid: false}
+		return nil
+	}
+	err := d.Duration.UnmarshalJSON(data)
+	if err != nil {
+		return err
+	}
+	d.Valid = true
+	return nil
+}
+
+// MarshalText implements [encoding.TextMarshaler] interface for NullDuration.
+func (d NullDuration) MarshalText() ([]byte, error) {
+	if !d.Valid {
+		return []byte{}, nil
+	}
+	return d.Duration.MarshalText()
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler] interface for NullDuration.
+func (d *NullDuration) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = NullDuration{Duration: 0, Valid: false}
+		return nil
+	}
+	err := d.Duration.UnmarshalText(text)
+	if err != nil {
+		return err
+	}
+	d.Valid = true
+	return nil
+}
+
+// String implements [fmt.Stringer] interface for NullDuration.
+//
+// It returns an empty string when absent.
+func (d NullDuration) String() string {
+	if !d.Valid {
+		return ""
+	}
+	return d.Duration.String()
+}
+
+// Scan implements [sql.Scanner] interface for NullDuration.
+func (d *NullDuration) Scan(src any) error {
+	if src == nil {
+		*d = NullDuration{Duration: 0, Valid: false}
+		return nil
+	}
+	err := d.Duration.Scan(src)
+	if err != nil {
+		return err
+	}
+	d.Valid = true
+	return nil
+}
+
+// Value implements [driver.Valuer] interface for NullDuration.
+func (d NullDuration) Value() (driver.Value, error) {
+	if !d.Valid {
+		return nil, nil //nolint:nilnil
+	}
+	return d.Duration.Value()
+}
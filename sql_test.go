@@ -0,0 +1,189 @@
+package x_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.com/tozd/go/x"
+)
+
+func TestTimeSQL(t *testing.T) {
+	t.Parallel()
+
+	value := time.Date(2023, time.November, 14, 22, 13, 20, 123456789, time.UTC)
+
+	v, err := x.Time(value).Value()
+	require.NoError(t, err)
+	assert.Equal(t, value, v)
+
+	for _, src := range []any{
+		value,
+		"2023-11-14T22:13:20.123456789Z",
+		[]byte("2023-11-14T22:13:20.123456789Z"),
+		"1700000000.123456789",
+	} {
+		var xt x.Time
+		err := xt.Scan(src)
+		require.NoError(t, err, "% -+#.1v", err)
+		assert.True(t, value.Equal(time.Time(xt)), "%v: got %v", src, time.Time(xt).UTC())
+	}
+
+	var xt x.Time
+	err = xt.Scan(int64(1700000000))
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.True(t, value.Truncate(time.Second).Equal(time.Time(xt)))
+
+	err = xt.Scan(1700000000.5)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.True(t, time.Unix(1700000000, 500000000).Equal(time.Time(xt)))
+
+	err = xt.Scan(nil)
+	assert.ErrorIs(t, err, x.ErrScanSQLValue)
+	err = xt.Scan(true)
+	assert.ErrorIs(t, err, x.ErrScanSQLValue)
+	err = xt.Scan(math.NaN())
+	assert.ErrorIs(t, err, x.ErrInvalidTimestamp)
+	err = xt.Scan("invalid")
+	assert.Error(t, err)
+}
+
+func TestDurationSQL(t *testing.T) {
+	t.Parallel()
+
+	d := x.Duration(90 * time.Minute)
+
+	v, err := d.Value()
+	require.NoError(t, err)
+	assert.Equal(t, int64(90*time.Minute), v)
+
+	for _, src := range []any{int64(90 * time.Minute), "1h30m", []byte("PT1H30M"), "5400"} {
+		var d2 x.Duration
+		err := d2.Scan(src)
+		require.NoError(t, err, "% -+#.1v", err)
+		assert.Equal(t, d, d2, src)
+	}
+
+	var d2 x.Duration
+	err = d2.Scan(nil)
+	assert.ErrorIs(t, err, x.ErrScanSQLValue)
+	err = d2.Scan(1.5)
+	assert.ErrorIs(t, err, x.ErrScanSQLValue)
+	err = d2.Scan("invalid")
+	assert.ErrorIs(t, err, x.ErrInvalidDuration)
+}
+
+func TestNullTime(t *testing.T) {
+	t.Parallel()
+
+	value := time.Date(2023, time.November, 14, 22, 13, 20, 123000000, time.UTC)
+
+	type Struct struct {
+		Time x.NullTime `json:"time"`
+	}
+
+	data, err := json.Marshal(Struct{Time: x.NullTime{Time: x.Time(value), Valid: true}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"time":"2023-11-14T22:13:20.123Z"}`, string(data))
+
+	data, err = json.Marshal(Struct{Time: x.NullTime{Time: x.Time{}, Valid: false}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"time":null}`, string(data))
+
+	s := Struct{Time: x.NullTime{Time: x.Time(value), Valid: true}}
+	err = json.Unmarshal([]byte(`{"time":null}`), &s)
+	require.NoError(t, err)
+	assert.Equal(t, x.NullTime{Time: x.Time{}, Valid: false}, s.Time)
+
+	err = json.Unmarshal([]byte(`{"time":1700000000.123}`), &s)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.True(t, s.Time.Valid)
+	assert.True(t, value.Equal(time.Time(s.Time.Time)))
+
+	err = json.Unmarshal([]byte(`{"time":"invalid"}`), &s)
+	assert.Error(t, err)
+
+	text, err := x.NullTime{Time: x.Time{}, Valid: false}.MarshalText()
+	require.NoError(t, err)
+	assert.Empty(t, text)
+	text, err = x.NullTime{Time: x.Time(value), Valid: true}.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "2023-11-14T22:13:20.123Z", string(text))
+	assert.Equal(t, "2023-11-14T22:13:20.123Z", x.NullTime{Time: x.Time(value), Valid: true}.String())
+	assert.Empty(t, x.NullTime{Time: x.Time(value), Valid: false}.String())
+
+	var nt x.NullTime
+	err = nt.UnmarshalText([]byte("2023-11-14T22:13:20.123Z"))
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.True(t, nt.Valid)
+	err = nt.UnmarshalText([]byte{})
+	require.NoError(t, err)
+	assert.False(t, nt.Valid)
+
+	err = nt.Scan(value)
+	require.NoError(t, err)
+	assert.Equal(t, x.NullTime{Time: x.Time(value), Valid: true}, nt)
+	v, err := nt.Value()
+	require.NoError(t, err)
+	assert.Equal(t, value, v)
+
+	err = nt.Scan(nil)
+	require.NoError(t, err)
+	assert.False(t, nt.Valid)
+	v, err = nt.Value()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestNullDuration(t *testing.T) {
+	t.Parallel()
+
+	d := x.Duration(90 * time.Minute)
+
+	data, err := json.Marshal([]x.NullDuration{{Duration: d, Valid: true}, {Duration: 0, Valid: false}})
+	require.NoError(t, err)
+	assert.Equal(t, `["1h30m0s",null]`, string(data))
+
+	var ds []x.NullDuration
+	err = json.Unmarshal([]byte(`["PT1H30M",null,5400]`), &ds)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, []x.NullDuration{{Duration: d, Valid: true}, {Duration: 0, Valid: false}, {Duration: d, Valid: true}}, ds)
+
+	err = json.Unmarshal([]byte(`["invalid"]`), &ds)
+	assert.Error(t, err)
+
+	text, err := x.NullDuration{Duration: d, Valid: false}.MarshalText()
+	require.NoError(t, err)
+	assert.Empty(t, text)
+	text, err = x.NullDuration{Duration: d, Valid: true}.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "1h30m0s", string(text))
+	assert.Equal(t, "1h30m0s", x.NullDuration{Duration: d, Valid: true}.String())
+	assert.Empty(t, x.NullDuration{Duration: d, Valid: false}.String())
+
+	var nd x.NullDuration
+	err = nd.UnmarshalText([]byte("1h30m"))
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, x.NullDuration{Duration: d, Valid: true}, nd)
+	err = nd.UnmarshalText([]byte{})
+	require.NoError(t, err)
+	assert.Equal(t, x.NullDuration{Duration: 0, Valid: false}, nd)
+
+	err = nd.Scan(int64(d))
+	require.NoError(t, err)
+	assert.Equal(t, x.NullDuration{Duration: d, Valid: true}, nd)
+	v, err := nd.Value()
+	require.NoError(t, err)
+	assert.Equal(t, int64(d), v)
+
+	err = nd.Scan(nil)
+	require.NoError(t, err)
+	assert.False(t, nd.Valid)
+	v, err = nd.Value()
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
@@ -2,18 +2,83 @@ package x
 
 import (
 	"math"
+	"math/big"
 	"time"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+var (
+	ErrInvalidTimestamp    = errors.Base("invalid timestamp")
+	ErrTimestampPrecision  = errors.Base("timestamp has sub-nanosecond precision")
+	ErrTimestampOutOfRange = errors.Base("timestamp out of range")
 )
 
+// unixToInternal is the number of seconds between year 1 (the zero time.Time)
+// and the Unix epoch. Time.Unix overflows for seconds larger than math.MaxInt64 minus it.
+const unixToInternal = (1969*365 + 1969/4 - 1969/100 + 1969/400) * 24 * 60 * 60
+
+var nanosecondsPerSecond = big.NewInt(int64(time.Second)) //nolint:gochecknoglobals
+
 // TimeToFloat64 returns the float64 representation of a time.Time,
 // as seconds since the Unix epoch.
+//
+// float64 cannot represent nanoseconds for current dates,
+// use TimeToRat or FormatTimestamp for an exact representation.
 func TimeToFloat64(t time.Time) float64 {
 	return float64(t.Unix()) + float64(t.Nanosecond())/1e9
 }
 
 // TimeFromFloat64 converts float64 seconds since the Unix epoch
-// to time.Time.
+// to time.Time, rounding to the nearest nanosecond.
 func TimeFromFloat64(t float64) time.Time {
 	sec := math.Trunc(t)
-	return time.Unix(int64(sec), int64((t-sec)*1e9)) //nolint:mnd
+	// time.Unix normalizes nanoseconds rounded up to a whole second.
+	return time.Unix(int64(sec), int64(math.Round((t-sec)*1e9))) //nolint:mnd
+}
+
+// TimeToRat returns the exact rational representation of a time.Time,
+// as seconds since the Unix epoch.
+func TimeToRat(t time.Time) *big.Rat {
+	ns := new(big.Int).Mul(big.NewInt(t.Unix()), nanosecondsPerSecond)
+	ns.Add(ns, big.NewInt(int64(t.Nanosecond())))
+	return new(big.Rat).SetFrac(ns, nanosecondsPerSecond)
+}
+
+// TimeFromRat converts rational seconds since the Unix epoch to time.Time.
+//
+// It returns an error if the value is not a whole number of nanoseconds
+// (use RoundRat with 9 places first to round it) or if it cannot be
+// represented by time.Time.
+func TimeFromRat(r *big.Rat) (time.Time, errors.E) {
+	ns, rem := new(big.Int).QuoRem(new(big.Int).Mul(r.Num(), nanosecondsPerSecond), r.Denom(), new(big.Int))
+	if rem.Sign() != 0 {
+		return time.Time{}, errors.WithDetails(ErrTimestampPrecision, "value", r.String())
+	}
+
+	// DivMod uses Euclidean division, so nsec is non-negative also for times before the Unix epoch.
+	sec, nsec := new(big.Int).DivMod(ns, nanosecondsPerSecond, new(big.Int))
+	if !sec.IsInt64() || sec.Int64() > math.MaxInt64-unixToInternal {
+		return time.Time{}, errors.WithDetails(ErrTimestampOutOfRange, "value", r.String())
+	}
+
+	return time.Unix(sec.Int64(), nsec.Int64()), nil
+}
+
+// FormatTimestamp formats t as an exact decimal number of seconds since
+// the Unix epoch, without trailing zeros, e.g., "1700000000.123456789".
+func FormatTimestamp(t time.Time) string {
+	return FormatRat(TimeToRat(t))
+}
+
+// ParseTimestamp parses s as an exact decimal number of seconds since
+// the Unix epoch, e.g., "1700000000.123456789". It is the inverse of FormatTimestamp.
+//
+// See TimeFromRat for possible errors.
+func ParseTimestamp(s string) (time.Time, errors.E) {
+	r, errE := ParseRat(s)
+	if errE != nil {
+		return time.Time{}, errors.WrapWith(errE, ErrInvalidTimestamp)
+	}
+	return TimeFromRat(r)
 }
@@ -1,10 +1,13 @@
 package x_test
 
 import (
+	"math"
+	"math/big"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"gitlab.com/tozd/go/x"
 )
@@ -38,6 +41,9 @@ func TestTimeFromFloat64(t *testing.T) {
 		{-0.5, time.Date(1969, time.December, 31, 23, 59, 59, 500000000, time.UTC)},
 		{-1.25, time.Date(1969, time.December, 31, 23, 59, 58, 750000000, time.UTC)},
 		{946684800, time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{0.3, time.Date(1970, time.January, 1, 0, 0, 0, 300000000, time.UTC)},
+		{1.9999999999, time.Date(1970, time.January, 1, 0, 0, 2, 0, time.UTC)},
+		{-1.9999999999, time.Date(1969, time.December, 31, 23, 59, 58, 0, time.UTC)},
 	}
 	for _, tt := range tests {
 		got := x.TimeFromFloat64(tt.value)
@@ -63,3 +69,90 @@ func TestTimeFromFloat64Large(t *testing.T) {
 		assert.Equal(t, 0, got.Nanosecond(), "value %v", tt)
 	}
 }
+
+func TestTimestamp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value    time.Time
+		expected string
+	}{
+		{time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC), "0"},
+		{time.Date(1970, time.January, 1, 0, 0, 1, 500000000, time.UTC), "1.5"},
+		{time.Date(1969, time.December, 31, 23, 59, 59, 500000000, time.UTC), "-0.5"},
+		{time.Date(1969, time.December, 31, 23, 59, 58, 999999999, time.UTC), "-1.000000001"},
+		{time.Date(2023, time.November, 14, 22, 13, 20, 123456789, time.UTC), "1700000000.123456789"},
+		{time.Date(2023, time.November, 14, 22, 13, 20, 1, time.UTC), "1700000000.000000001"},
+		{time.Date(1_000_000_000, time.January, 1, 0, 0, 0, 1, time.UTC), "31556889832780800.000000001"},
+		{time.Date(-1_000_000_000, time.January, 1, 0, 0, 0, 0, time.UTC), "-31557014167219200"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, x.FormatTimestamp(tt.value), "value %v", tt.value)
+
+		got, errE := x.ParseTimestamp(tt.expected)
+		require.NoError(t, errE, "% -+#.1v", errE)
+		assert.True(t, tt.value.Equal(got), "value %v: got %v", tt.value, got.UTC())
+
+		r, ok := new(big.Rat).SetString(tt.expected)
+		require.True(t, ok)
+		assert.Equal(t, r, x.TimeToRat(tt.value))
+	}
+
+	// Trailing zeros and an explicit sign are accepted.
+	got, errE := x.ParseTimestamp("+1700000000.123000000")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.True(t, time.Unix(1700000000, 123000000).Equal(got))
+
+	// float64 cannot represent this exactly.
+	assert.NotEqual(t, 123456789, x.TimeFromFloat64(x.TimeToFloat64(time.Unix(1700000000, 123456789))).Nanosecond())
+}
+
+func TestTimestampErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"", "abc", "1e9", "1.2.3", "0x10", " 1"} {
+		_, errE := x.ParseTimestamp(s)
+		assert.ErrorIs(t, errE, x.ErrInvalidTimestamp, s)
+	}
+
+	_, errE := x.ParseTimestamp("1700000000.1234567891")
+	assert.ErrorIs(t, errE, x.ErrTimestampPrecision)
+	_, errE = x.ParseTimestamp("0.(3)")
+	assert.ErrorIs(t, errE, x.ErrTimestampPrecision)
+	_, errE = x.ParseTimestamp("9223372036854775808")
+	assert.ErrorIs(t, errE, x.ErrTimestampOutOfRange)
+	_, errE = x.TimeFromRat(new(big.Rat).SetInt64(math.MaxInt64))
+	assert.ErrorIs(t, errE, x.ErrTimestampOutOfRange)
+
+	// Rounding first makes the value representable.
+	r, _ := x.ParseRat("1700000000.1234567891")
+	rounded, _ := x.RoundRat(r, 9, x.RoundHalfEven)
+	got, errE := x.TimeFromRat(rounded)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.True(t, time.Unix(1700000000, 123456789).Equal(got))
+}
+
+func FuzzTimestamp(f *testing.F) {
+	f.Add(int64(0), int64(0))
+	f.Add(int64(1700000000), int64(123456789))
+	f.Add(int64(-1), int64(1))
+	f.Add(int64(math.MinInt64), int64(0))
+	f.Add(int64(math.MaxInt64-62135596800), int64(999999999))
+
+	f.Fuzz(func(t *testing.T, sec, nsec int64) {
+		tt := time.Unix(sec, nsec)
+		if tt.Unix() > math.MaxInt64-62135596800 {
+			// time.Time cannot represent this value.
+			t.Skip()
+		}
+
+		s := x.FormatTimestamp(tt)
+		got, errE := x.ParseTimestamp(s)
+		require.NoError(t, errE, "% -+#.1v", errE)
+		assert.True(t, tt.Equal(got), "%s: expected %v, got %v", s, tt.UTC(), got.UTC())
+
+		got, errE = x.TimeFromRat(x.TimeToRat(tt))
+		require.NoError(t, errE, "% -+#.1v", errE)
+		assert.True(t, tt.Equal(got), "%s: expected %v, got %v", s, tt.UTC(), got.UTC())
+	})
+}